@@ -0,0 +1,241 @@
+package main
+
+import (
+	"context"
+	e "errors"
+	"fmt"
+	"log/slog"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/pkg/errors"
+
+	"github.com/goto/transformers/mc2mc/internal/config"
+	"github.com/goto/transformers/mc2mc/pkg/query"
+)
+
+// stagingClient provides table metadata and data of the staging table
+type stagingClient interface {
+	query.OdpsClient
+	TableExists(tableID string) (bool, error)
+	GetPartitionValues(ctx context.Context, tableID string) ([]string, error)
+	CountRows(ctx context.Context, tableID string) (int64, error)
+}
+
+// atomicExecutor executes the queries of atomic replace, checkpoints of the previous attempt
+// are discarded when the staging table they wrote into doesn't exist anymore
+type atomicExecutor interface {
+	queryExecutor
+	DiscardCheckpoints()
+}
+
+// executeAtomicReplace writes the generated REPLACE queries into a staging table with the destination schema,
+// then moves the validated staging data into the destination with a single query, so a failure halfway
+// doesn't leave the destination with a mix of new and old partitions.
+// The staging table is dropped afterwards, it's only kept after a failure when resume is enabled
+// so the next attempt resumes writing into it, a cancelled run always drops it.
+func executeAtomicReplace(ctx context.Context, l *slog.Logger, cfg *config.Config, c atomicExecutor, policy failurePolicy, sc stagingClient, runKey string, raw string, queryColumns []query.Column) (err error) {
+	// schema changes are applied to the destination first, so the staging table gets them as well,
+	// the sequence is reserved even without new column so the next attempt gets the same sequences
+	offset := 0
+	if cfg.AllowFieldAddition {
+		addColumnsQuery, err := query.ConstructAddColumnsQuery(l, sc, cfg.DestinationTableID, queryColumns)
+		if err != nil {
+			return errors.WithStack(err)
+		}
+		if addColumnsQuery != "" {
+			if err := execute(ctx, l, c, offset, []string{addColumnsQuery}, cfg.AdditionalHints); err != nil {
+				return errors.WithStack(err)
+			}
+		}
+		offset++
+	}
+
+	// statements of the previous attempt are executed again if their staging table is dropped
+	stagingTableID := stagingTableName(cfg.DestinationTableID, runKey)
+	exists, err := sc.TableExists(stagingTableID)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	if !exists {
+		c.DiscardCheckpoints()
+	}
+
+	executeFn := c.ExecuteFn(0)
+	if err := executeFn(ctx, query.ConstructStagingTableQuery(stagingTableID, cfg.DestinationTableID), cfg.AdditionalHints); err != nil {
+		return errors.WithStack(err)
+	}
+	defer func() {
+		switch {
+		case err == nil:
+			l.Info(fmt.Sprintf("dropping staging table %s", stagingTableID))
+		case ctx.Err() != nil:
+			l.Info(fmt.Sprintf("run is cancelled, dropping staging table %s", stagingTableID))
+		case cfg.Resume:
+			l.Info(fmt.Sprintf("run is failed, staging table %s is kept for the next attempt to resume", stagingTableID))
+			return
+		default:
+			l.Info(fmt.Sprintf("run is failed and resume is not enabled, dropping staging table %s", stagingTableID))
+		}
+		if dropErr := executeFn(context.WithoutCancel(ctx), query.ConstructDropTableQuery(stagingTableID), cfg.AdditionalHints); dropErr != nil {
+			err = e.Join(err, errors.WithStack(dropErr))
+		}
+	}()
+
+	// write every generated query into the staging table
+	stagingConfigEnv := *cfg.ConfigEnv
+	stagingConfigEnv.DestinationTableID = stagingTableID
+	stagingConfigEnv.AllowFieldAddition = false
+	stagingCfg := &config.Config{Config: cfg.Config, ConfigEnv: &stagingConfigEnv}
+	stagingQueries, err := generateJobQueries(l, stagingCfg, query.NewCachedClient(sc), raw, queryColumns)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	if cfg.BackfillChunkSize > 0 {
+		err = executeBackfill(ctx, l, c, stagingCfg, policy, offset, stagingQueries)
+	} else {
+		queriesToExecute := make([]string, len(stagingQueries))
+		for i, stagingQuery := range stagingQueries {
+			queriesToExecute[i] = stagingQuery.query
+		}
+		err = executeConcurrently(ctx, l, c, cfg.Concurrency, policy, offset, queriesToExecute, cfg.AdditionalHints)
+	}
+	if err != nil {
+		return errors.Wrapf(err, "failed to write staging table %s, destination %s is not replaced", stagingTableID, cfg.DestinationTableID)
+	}
+
+	// validate staging data before replacing the destination
+	if err := validateStaging(ctx, l, cfg, sc, stagingTableID, stagingQueries); err != nil {
+		return errors.WithStack(err)
+	}
+
+	// move staging data into the destination
+	var partitionNames []string
+	if cfg.DevEnableAutoPartition != "true" {
+		partitionNames, err = sc.GetPartitionNames(ctx, cfg.DestinationTableID)
+		if err != nil {
+			return errors.WithStack(err)
+		}
+	}
+	if err := executeFn(ctx, query.ConstructSwapQuery(cfg.DestinationTableID, stagingTableID, partitionNames), cfg.AdditionalHints); err != nil {
+		return errors.WithStack(err)
+	}
+	l.Info(fmt.Sprintf("destination %s is replaced from staging table %s", cfg.DestinationTableID, stagingTableID))
+	return nil
+}
+
+// validateStaging verifies the staging table has the data of every generated date before it replaces the destination,
+// a date without data would keep the old partition of the destination. A date is matched to the staging partitions
+// by the static partition values of its query, when the partitions are only dynamic the staging table must have
+// a partition per date at least. Non partitioned staging table must not be empty since it replaces the whole destination.
+func validateStaging(ctx context.Context, l *slog.Logger, cfg *config.Config, sc stagingClient, stagingTableID string, generatedQueries []generatedQuery) error {
+	partitionNames, err := sc.GetPartitionNames(ctx, stagingTableID)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	if len(partitionNames) == 0 {
+		count, err := sc.CountRows(ctx, stagingTableID)
+		if err != nil {
+			return errors.WithStack(err)
+		}
+		l.Info(fmt.Sprintf("staging table %s has %d rows", stagingTableID, count))
+		if count == 0 {
+			return rejectStaging(l, cfg, fmt.Sprintf("staging table %s is empty", stagingTableID))
+		}
+		return nil
+	}
+
+	partitionValues, err := sc.GetPartitionValues(ctx, stagingTableID)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	partitions := make([]map[string]string, len(partitionValues))
+	for i, partitionValue := range partitionValues {
+		partitions[i] = parsePartitionValue(partitionValue)
+	}
+	l.Info(fmt.Sprintf("staging table %s has %d partitions", stagingTableID, len(partitions)))
+
+	start, _, err := parseWindow(cfg)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	dates := []string{}
+	missingDates := []string{}
+	for _, generatedQuery := range generatedQueries {
+		if slices.Contains(dates, generatedQuery.date) {
+			continue
+		}
+		dates = append(dates, generatedQuery.date)
+		t, err := time.ParseInLocation(time.DateTime, generatedQuery.date, start.Location())
+		if err != nil {
+			return errors.WithStack(err)
+		}
+		expected := staticPartitionValues(cfg, t)
+		if len(expected) == 0 {
+			continue
+		}
+		if !slices.ContainsFunc(partitions, func(partition map[string]string) bool { return matchPartition(partition, expected) }) {
+			missingDates = append(missingDates, generatedQuery.date)
+		}
+	}
+	if len(missingDates) > 0 {
+		return rejectStaging(l, cfg, fmt.Sprintf("staging table %s has no partition for dates %v", stagingTableID, missingDates))
+	}
+	if len(partitions) < len(dates) {
+		return rejectStaging(l, cfg, fmt.Sprintf("staging table %s has %d partitions for %d dates", stagingTableID, len(partitions), len(dates)))
+	}
+	return nil
+}
+
+// rejectStaging returns error of the invalid staging data unless it's allowed to replace the destination anyway
+func rejectStaging(l *slog.Logger, cfg *config.Config, reason string) error {
+	if cfg.AtomicReplaceAllowEmpty {
+		l.Warn(fmt.Sprintf("%s, destination %s is replaced anyway", reason, cfg.DestinationTableID))
+		return nil
+	}
+	return errors.Errorf("%s, destination %s is not replaced", reason, cfg.DestinationTableID)
+}
+
+// staticPartitionValues returns the static partition values of the query generated for the given window date
+func staticPartitionValues(cfg *config.Config, t time.Time) map[string]string {
+	values := query.StaticPartitionValues(cfg.PartitionValues, t)
+	for column, value := range cfg.PartitionSpec {
+		values[column] = value
+	}
+	if cfg.PartitionDateColumn != "" {
+		values[cfg.PartitionDateColumn] = t.Format(cfg.PartitionDateFormat)
+	}
+	return values
+}
+
+// parsePartitionValue parses partition value like dt=2024-01-01/hh=00 by lowercased column name
+func parsePartitionValue(partitionValue string) map[string]string {
+	partition := map[string]string{}
+	for _, part := range strings.Split(partitionValue, "/") {
+		name, value, _ := strings.Cut(part, "=")
+		partition[strings.ToLower(name)] = value
+	}
+	return partition
+}
+
+// matchPartition returns true if the partition has all the expected values
+func matchPartition(partition, expected map[string]string) bool {
+	for column, value := range expected {
+		if partition[strings.ToLower(column)] != value {
+			return false
+		}
+	}
+	return true
+}
+
+// stagingTableName returns the name of the staging table of the run, it's derived from the run key
+// so the statements writing into it are the same on every attempt of the run,
+// run without run key is never resumed so it gets a random one
+func stagingTableName(destinationTableID, runKey string) string {
+	if runKey == "" {
+		runKey = strings.ReplaceAll(uuid.NewString(), "-", "")
+	}
+	return fmt.Sprintf("%s_mc2mc_staging_%s", destinationTableID, runKey[:16])
+}
@@ -0,0 +1,207 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/goto/transformers/mc2mc/internal/client"
+	"github.com/goto/transformers/mc2mc/internal/config"
+	"github.com/goto/transformers/mc2mc/internal/logger"
+	"github.com/goto/transformers/mc2mc/pkg/query"
+)
+
+type mockStagingClient struct {
+	mockOdpsClient
+	exists          bool
+	partitionValues []string
+	rows            int64
+}
+
+func (m *mockStagingClient) TableExists(tableID string) (bool, error) {
+	return m.exists, nil
+}
+
+func (m *mockStagingClient) GetPartitionValues(ctx context.Context, tableID string) ([]string, error) {
+	return m.partitionValues, nil
+}
+
+func (m *mockStagingClient) CountRows(ctx context.Context, tableID string) (int64, error) {
+	return m.rows, nil
+}
+
+func TestExecuteAtomicReplace(t *testing.T) {
+	const (
+		runKey         = "0123456789abcdef0123456789abcdef"
+		stagingTableID = "project.playground.table_destination_mc2mc_staging_0123456789abcdef"
+		raw            = "select id, name from project.playground.table where dt = '2024-01-01';\n" + query.BREAK_MARKER + "\nselect id, name from project.playground.table where dt = '2024-01-02';"
+	)
+	newConfig := func(t *testing.T, envs ...string) *config.Config {
+		cfg, err := config.NewConfig(append([]string{
+			"LOAD_METHOD=REPLACE",
+			"DESTINATION_TABLE_ID=project.playground.table_destination",
+			"DSTART=2024-01-01T00:00:00Z",
+			"DEND=2024-01-03T00:00:00Z",
+			"PARTITION_DATE_COLUMN=dt",
+			"ATOMIC_REPLACE=true",
+		}, envs...)...)
+		assert.NoError(t, err)
+		return cfg
+	}
+	newStagingClient := func(partitionValues ...string) *mockStagingClient {
+		return &mockStagingClient{
+			mockOdpsClient:  mockOdpsClient{orderedColumns: []string{"id", "name"}, partitionNames: []string{"dt"}},
+			partitionValues: partitionValues,
+		}
+	}
+	createQuery := query.ConstructStagingTableQuery(stagingTableID, "project.playground.table_destination")
+	swapQuery := query.ConstructSwapQuery("project.playground.table_destination", stagingTableID, []string{"dt"})
+	dropQuery := query.ConstructDropTableQuery(stagingTableID)
+
+	t.Run("writes queries into staging table and swaps it into destination", func(t *testing.T) {
+		executor := &fakeExecutor{}
+		sc := newStagingClient("dt=2024-01-01", "dt=2024-01-02")
+
+		err := executeAtomicReplace(context.Background(), logger.NewDefaultLogger(), newConfig(t), executor, failurePolicy{}, sc, runKey, raw, nil)
+		assert.NoError(t, err)
+		assert.Len(t, executor.queries, 5)
+		assert.Equal(t, createQuery, executor.queries[0])
+		assert.Equal(t, []string{swapQuery, dropQuery}, executor.queries[3:])
+		assert.True(t, strings.HasPrefix(executor.executed[1], "INSERT OVERWRITE TABLE "+stagingTableID+" PARTITION (dt='2024-01-01')"), executor.executed[1])
+		assert.True(t, strings.HasPrefix(executor.executed[2], "INSERT OVERWRITE TABLE "+stagingTableID+" PARTITION (dt='2024-01-02')"), executor.executed[2])
+		assert.True(t, executor.discarded, "checkpoints are discarded for new staging table")
+	})
+	t.Run("keeps checkpoints when staging table of the previous attempt exists", func(t *testing.T) {
+		executor := &fakeExecutor{}
+		sc := newStagingClient("dt=2024-01-01", "dt=2024-01-02")
+		sc.exists = true
+
+		err := executeAtomicReplace(context.Background(), logger.NewDefaultLogger(), newConfig(t, "RESUME=true"), executor, failurePolicy{}, sc, runKey, raw, nil)
+		assert.NoError(t, err)
+		assert.False(t, executor.discarded)
+	})
+	t.Run("drops staging table without replacing destination when staging misses a date", func(t *testing.T) {
+		executor := &fakeExecutor{}
+		sc := newStagingClient("dt=2024-01-01")
+
+		err := executeAtomicReplace(context.Background(), logger.NewDefaultLogger(), newConfig(t), executor, failurePolicy{}, sc, runKey, raw, nil)
+		assert.ErrorContains(t, err, "staging table "+stagingTableID+" has no partition for dates [2024-01-02 00:00:00], destination project.playground.table_destination is not replaced")
+		assert.NotContains(t, executor.queries, swapQuery)
+		assert.Equal(t, dropQuery, executor.queries[len(executor.queries)-1])
+	})
+	t.Run("drops staging table without replacing destination when writing fails", func(t *testing.T) {
+		executor := &fakeExecutor{fn: func(ctx context.Context, id int, query string) error {
+			if id == 2 {
+				return errors.New("write failed")
+			}
+			return nil
+		}}
+		sc := newStagingClient("dt=2024-01-01", "dt=2024-01-02")
+
+		err := executeAtomicReplace(context.Background(), logger.NewDefaultLogger(), newConfig(t), executor, failurePolicy{}, sc, runKey, raw, nil)
+		assert.ErrorContains(t, err, "write failed")
+		assert.NotContains(t, executor.queries, swapQuery)
+		assert.Equal(t, dropQuery, executor.queries[len(executor.queries)-1])
+	})
+	t.Run("keeps staging table for the next attempt when writing fails with resume", func(t *testing.T) {
+		executor := &fakeExecutor{fn: func(ctx context.Context, id int, query string) error {
+			if id == 2 {
+				return errors.New("write failed")
+			}
+			return nil
+		}}
+		sc := newStagingClient("dt=2024-01-01", "dt=2024-01-02")
+
+		err := executeAtomicReplace(context.Background(), logger.NewDefaultLogger(), newConfig(t, "RESUME=true"), executor, failurePolicy{}, sc, runKey, raw, nil)
+		assert.ErrorContains(t, err, "write failed")
+		assert.NotContains(t, executor.queries, swapQuery)
+		assert.NotContains(t, executor.queries, dropQuery)
+	})
+	t.Run("drops staging table with uncancelled context when run is cancelled with resume", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		dropped := false
+		executor := &fakeExecutor{fn: func(ctx context.Context, id int, query string) error {
+			if query == dropQuery {
+				dropped = ctx.Err() == nil
+			}
+			if id > 0 {
+				cancel()
+				return fmt.Errorf("%w: %w", client.ErrTerminated, context.Cause(ctx))
+			}
+			return nil
+		}}
+		sc := newStagingClient("dt=2024-01-01", "dt=2024-01-02")
+
+		err := executeAtomicReplace(ctx, logger.NewDefaultLogger(), newConfig(t, "RESUME=true", "CONCURRENCY=1"), executor, failurePolicy{}, sc, runKey, raw, nil)
+		assert.ErrorIs(t, err, context.Canceled)
+		assert.NotContains(t, executor.queries, swapQuery)
+		assert.True(t, dropped)
+	})
+	t.Run("adds new query columns to destination before creating staging table", func(t *testing.T) {
+		executor := &fakeExecutor{}
+		sc := newStagingClient("dt=2024-01-01", "dt=2024-01-02")
+
+		err := executeAtomicReplace(context.Background(), logger.NewDefaultLogger(), newConfig(t, "ALLOW_FIELD_ADDITION=true"), executor, failurePolicy{}, sc, runKey, raw,
+			[]query.Column{{Name: "id", Type: "BIGINT"}, {Name: "name", Type: "STRING"}, {Name: "age", Type: "INT"}})
+		assert.NoError(t, err)
+		assert.Equal(t, "ALTER TABLE project.playground.table_destination ADD COLUMNS IF NOT EXISTS (age INT)\n;", executor.executed[1])
+		assert.Equal(t, createQuery, executor.queries[1])
+		assert.True(t, strings.HasPrefix(executor.executed[2], "INSERT OVERWRITE TABLE "+stagingTableID+" PARTITION (dt='2024-01-01')"), executor.executed[2])
+		assert.True(t, strings.HasPrefix(executor.executed[3], "INSERT OVERWRITE TABLE "+stagingTableID+" PARTITION (dt='2024-01-02')"), executor.executed[3])
+	})
+}
+
+func TestValidateStaging(t *testing.T) {
+	generatedQueries := []generatedQuery{
+		{date: "2024-01-01 00:00:00", query: "q1"},
+		{date: "2024-01-02 00:00:00", query: "q2"},
+	}
+	newConfig := func(t *testing.T, envs ...string) *config.Config {
+		cfg, err := config.NewConfig(append([]string{
+			"LOAD_METHOD=REPLACE",
+			"DESTINATION_TABLE_ID=project.playground.table_destination",
+			"DSTART=2024-01-01T00:00:00Z",
+			"DEND=2024-01-03T00:00:00Z",
+		}, envs...)...)
+		assert.NoError(t, err)
+		return cfg
+	}
+
+	t.Run("matches dates by static partition values", func(t *testing.T) {
+		sc := &mockStagingClient{
+			mockOdpsClient:  mockOdpsClient{partitionNames: []string{"dt", "region"}},
+			partitionValues: []string{"dt=20240101/region=id", "DT=20240102/region=id"},
+		}
+		err := validateStaging(context.Background(), logger.NewDefaultLogger(), newConfig(t, "PARTITION_VALUES=dt=date:20060102;region=dynamic"), sc, "staging", generatedQueries)
+		assert.NoError(t, err)
+	})
+	t.Run("returns error when dynamic partitions are less than dates", func(t *testing.T) {
+		sc := &mockStagingClient{
+			mockOdpsClient:  mockOdpsClient{partitionNames: []string{"dt"}},
+			partitionValues: []string{"dt=2024-01-01"},
+		}
+		err := validateStaging(context.Background(), logger.NewDefaultLogger(), newConfig(t), sc, "staging", generatedQueries)
+		assert.ErrorContains(t, err, "staging table staging has 1 partitions for 2 dates")
+	})
+	t.Run("allows missing dates when it's configured", func(t *testing.T) {
+		sc := &mockStagingClient{
+			mockOdpsClient: mockOdpsClient{partitionNames: []string{"dt"}},
+		}
+		err := validateStaging(context.Background(), logger.NewDefaultLogger(), newConfig(t, "PARTITION_DATE_COLUMN=dt", "ATOMIC_REPLACE_ALLOW_EMPTY=true"), sc, "staging", generatedQueries)
+		assert.NoError(t, err)
+	})
+	t.Run("returns error for empty non partitioned staging table", func(t *testing.T) {
+		sc := &mockStagingClient{}
+		err := validateStaging(context.Background(), logger.NewDefaultLogger(), newConfig(t), sc, "staging", generatedQueries)
+		assert.ErrorContains(t, err, "staging table staging is empty, destination project.playground.table_destination is not replaced")
+
+		sc.rows = 10
+		err = validateStaging(context.Background(), logger.NewDefaultLogger(), newConfig(t), sc, "staging", generatedQueries)
+		assert.NoError(t, err)
+	})
+}
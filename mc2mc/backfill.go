@@ -0,0 +1,151 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/goto/transformers/mc2mc/internal/config"
+	"github.com/goto/transformers/mc2mc/pkg/query"
+)
+
+// generateJobQueries generates the final queries of the job,
+// APPEND and MERGE are generated once per sub-window when BACKFILL_SPLIT_WINDOW is enabled
+func generateJobQueries(l *slog.Logger, cfg *config.Config, odpsClient query.OdpsClient, raw string, queryColumns []query.Column) ([]generatedQuery, error) {
+	if !cfg.BackfillSplitWindow || (cfg.Method() != query.APPEND && cfg.Method() != query.MERGE) {
+		return generateQueries(l, cfg, odpsClient, raw, queryColumns)
+	}
+
+	subWindowConfigs, err := splitWindow(l, cfg, odpsClient)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	generatedQueries := []generatedQuery{}
+	for _, subWindowConfig := range subWindowConfigs {
+		queries, err := generateQueries(l, subWindowConfig, odpsClient, raw, queryColumns)
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+		generatedQueries = append(generatedQueries, queries...)
+	}
+	return generatedQueries, nil
+}
+
+// splitWindow returns a copy of the config for each sub-window of the job window,
+// sub-windows are aligned to the granularity and bounded by DSTART and DEND
+func splitWindow(l *slog.Logger, cfg *config.Config, odpsClient query.OdpsClient) ([]*config.Config, error) {
+	start, end, err := parseWindow(cfg)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	granularity, err := resolveGranularity(l, cfg, odpsClient)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	configs := []*config.Config{}
+	for _, windowStart := range query.GenerateWindows(start, end, granularity) {
+		windowEnd := granularity.Next(windowStart)
+		if windowStart.Before(start) {
+			windowStart = start
+		}
+		if windowEnd.After(end) {
+			windowEnd = end
+		}
+		configEnv := *cfg.ConfigEnv
+		configEnv.DStart = windowStart.Format(time.RFC3339)
+		configEnv.DEnd = windowEnd.Format(time.RFC3339)
+		configs = append(configs, &config.Config{Config: cfg.Config, ConfigEnv: &configEnv})
+	}
+	return configs, nil
+}
+
+// chunkByDate groups the generated queries into chunks of the given number of dates,
+// the chunks are ordered by date, newest first if it's requested
+func chunkByDate(generatedQueries []generatedQuery, size int, newestFirst bool) [][]generatedQuery {
+	dates := []string{}
+	queriesByDate := map[string][]generatedQuery{}
+	for _, generatedQuery := range generatedQueries {
+		if _, ok := queriesByDate[generatedQuery.date]; !ok {
+			dates = append(dates, generatedQuery.date)
+		}
+		queriesByDate[generatedQuery.date] = append(queriesByDate[generatedQuery.date], generatedQuery)
+	}
+	slices.Sort(dates) // normalized date format is sortable
+	if newestFirst {
+		slices.Reverse(dates)
+	}
+
+	chunks := [][]generatedQuery{}
+	for i := 0; i < len(dates); i += size {
+		chunk := []generatedQuery{}
+		for _, date := range dates[i:min(i+size, len(dates))] {
+			chunk = append(chunk, queriesByDate[date]...)
+		}
+		chunks = append(chunks, chunk)
+	}
+	return chunks
+}
+
+// executeBackfill executes the generated queries in chunks of dates with a pause between them,
+// queries of a REPLACE chunk are executed concurrently, otherwise sequentially.
+// Sequence ids start after the offset and continue across the chunks, so every query
+// of the backfill is checkpointed under its own sequence.
+func executeBackfill(ctx context.Context, l *slog.Logger, c queryExecutor, cfg *config.Config, policy failurePolicy, offset int, generatedQueries []generatedQuery) error {
+	var newestFirst bool
+	switch strings.ToUpper(cfg.BackfillOrder) {
+	case "", "OLDEST_FIRST":
+	case "NEWEST_FIRST":
+		newestFirst = true
+	default:
+		return errors.Errorf("not supported backfill order: %s", cfg.BackfillOrder)
+	}
+
+	// schema changes must be done before any chunk
+	ddlQueries := []string{}
+	for len(generatedQueries) > 0 && query.IsDDL(generatedQueries[0].query) {
+		ddlQueries = append(ddlQueries, generatedQueries[0].query)
+		generatedQueries = generatedQueries[1:]
+	}
+	if len(ddlQueries) > 0 {
+		if err := execute(ctx, l, c, offset, ddlQueries, cfg.AdditionalHints); err != nil {
+			return errors.WithStack(err)
+		}
+		offset += len(ddlQueries)
+	}
+
+	chunks := chunkByDate(generatedQueries, cfg.BackfillChunkSize, newestFirst)
+	for i, chunk := range chunks {
+		if i > 0 && cfg.BackfillPause > 0 {
+			l.Info(fmt.Sprintf("pausing backfill for %s", cfg.BackfillPause))
+			select {
+			case <-ctx.Done():
+				return errors.WithStack(context.Cause(ctx))
+			case <-time.After(cfg.BackfillPause):
+			}
+		}
+
+		l.Info(fmt.Sprintf("backfill chunk %d of %d: %s to %s (%d queries)", i+1, len(chunks), chunk[0].date, chunk[len(chunk)-1].date, len(chunk)))
+		queriesToExecute := make([]string, len(chunk))
+		for j, generatedQuery := range chunk {
+			queriesToExecute[j] = generatedQuery.query
+		}
+		var err error
+		if cfg.Method() == query.REPLACE {
+			err = executeConcurrently(ctx, l, c, cfg.Concurrency, policy, offset, queriesToExecute, cfg.AdditionalHints)
+		} else {
+			err = execute(ctx, l, c, offset, queriesToExecute, cfg.AdditionalHints)
+		}
+		if err != nil {
+			return errors.Wrapf(err, "backfill chunk %d of %d failed", i+1, len(chunks))
+		}
+		offset += len(chunk)
+		l.Info(fmt.Sprintf("backfill progress: %d of %d chunks done", i+1, len(chunks)))
+	}
+	return nil
+}
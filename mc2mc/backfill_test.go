@@ -0,0 +1,108 @@
+package main
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/goto/transformers/mc2mc/internal/config"
+	"github.com/goto/transformers/mc2mc/internal/logger"
+)
+
+func TestChunkByDate(t *testing.T) {
+	generatedQueries := []generatedQuery{
+		{date: "2024-01-01 00:00:00", query: "q1"},
+		{date: "2024-01-01 00:00:00", query: "q2"},
+		{date: "2024-01-02 00:00:00", query: "q3"},
+		{date: "2024-01-03 00:00:00", query: "q4"},
+	}
+
+	t.Run("returns chunks of dates oldest first keeping queries of a date together", func(t *testing.T) {
+		chunks := chunkByDate(generatedQueries, 2, false)
+		assert.Equal(t, [][]generatedQuery{
+			{generatedQueries[0], generatedQueries[1], generatedQueries[2]},
+			{generatedQueries[3]},
+		}, chunks)
+	})
+	t.Run("returns chunks of dates newest first", func(t *testing.T) {
+		chunks := chunkByDate(generatedQueries, 2, true)
+		assert.Equal(t, [][]generatedQuery{
+			{generatedQueries[3], generatedQueries[2]},
+			{generatedQueries[0], generatedQueries[1]},
+		}, chunks)
+	})
+	t.Run("returns single chunk when chunk size covers all dates", func(t *testing.T) {
+		chunks := chunkByDate(generatedQueries, 3, false)
+		assert.Equal(t, [][]generatedQuery{generatedQueries}, chunks)
+	})
+}
+
+func TestExecuteBackfill(t *testing.T) {
+	t.Run("keeps one sequence across chunks", func(t *testing.T) {
+		for _, loadMethod := range []string{"REPLACE", "APPEND"} {
+			cfg, err := config.NewConfig(
+				"LOAD_METHOD="+loadMethod,
+				"BACKFILL_CHUNK_SIZE=1",
+				"BACKFILL_ORDER=NEWEST_FIRST",
+			)
+			assert.NoError(t, err)
+			executor := &fakeExecutor{}
+
+			err = executeBackfill(context.Background(), logger.NewDefaultLogger(), executor, cfg, failurePolicy{}, 1, []generatedQuery{
+				{date: "2024-01-01 00:00:00", query: "ALTER TABLE t ADD COLUMNS (c STRING)"},
+				{date: "2024-01-01 00:00:00", query: "insert t"},
+				{date: "2024-01-02 00:00:00", query: "insert t"},
+				{date: "2024-01-03 00:00:00", query: "insert t_3"},
+			})
+			assert.NoError(t, err)
+			assert.Equal(t, map[int]string{
+				2: "ALTER TABLE t ADD COLUMNS (c STRING)",
+				3: "insert t_3",
+				4: "insert t",
+				5: "insert t",
+			}, executor.executed, loadMethod)
+		}
+	})
+}
+
+func TestSplitWindow(t *testing.T) {
+	t.Run("returns sub-windows clamped to the job window", func(t *testing.T) {
+		cfg, err := config.NewConfig(
+			"LOAD_METHOD=APPEND",
+			"DSTART=2024-01-01T05:30:00Z",
+			"DEND=2024-01-01T08:15:00Z",
+			"REPLACE_GRANULARITY=HOUR",
+		)
+		assert.NoError(t, err)
+
+		configs, err := splitWindow(logger.NewDefaultLogger(), cfg, &mockOdpsClient{})
+		assert.NoError(t, err)
+		windows := [][2]string{}
+		for _, subWindowConfig := range configs {
+			windows = append(windows, [2]string{subWindowConfig.DStart, subWindowConfig.DEnd})
+		}
+		assert.Equal(t, [][2]string{
+			{"2024-01-01T05:30:00Z", "2024-01-01T06:00:00Z"},
+			{"2024-01-01T06:00:00Z", "2024-01-01T07:00:00Z"},
+			{"2024-01-01T07:00:00Z", "2024-01-01T08:00:00Z"},
+			{"2024-01-01T08:00:00Z", "2024-01-01T08:15:00Z"},
+		}, windows)
+		assert.Equal(t, "2024-01-01T05:30:00Z", cfg.DStart, "job config is not modified")
+	})
+	t.Run("returns daily sub-windows from the job start", func(t *testing.T) {
+		cfg, err := config.NewConfig(
+			"LOAD_METHOD=MERGE",
+			"DSTART=2024-01-01T05:00:00Z",
+			"DEND=2024-01-03T05:00:00Z",
+		)
+		assert.NoError(t, err)
+
+		configs, err := splitWindow(logger.NewDefaultLogger(), cfg, &mockOdpsClient{})
+		assert.NoError(t, err)
+		assert.Len(t, configs, 2)
+		assert.Equal(t, "2024-01-02T05:00:00Z", configs[0].DEnd)
+		assert.Equal(t, "2024-01-02T05:00:00Z", configs[1].DStart)
+		assert.Equal(t, "2024-01-03T05:00:00Z", configs[1].DEnd)
+	})
+}
@@ -0,0 +1,104 @@
+package main
+
+import (
+	"context"
+	e "errors"
+	"fmt"
+	"log/slog"
+	"slices"
+
+	"github.com/pkg/errors"
+
+	"github.com/goto/transformers/mc2mc/internal/client"
+)
+
+// dagResult is the result of a query executed by executeDAG
+type dagResult struct {
+	index int
+	err   error
+}
+
+// executeDAG executes the queries concurrently bounded by the concurrency,
+// a query starts only after the queries it depends on are succeeded.
+// No query starts after a failure, the running ones are waited to finish.
+func executeDAG(ctx context.Context, l *slog.Logger, c queryExecutor, concurrency int, queriesToExecute []string, dependencies [][]int, additionalHints map[string]string) error {
+	if concurrency < 1 {
+		concurrency = 1
+	}
+
+	// count remaining dependencies and collect dependents of each query
+	remaining := make([]int, len(queriesToExecute))
+	dependents := make([][]int, len(queriesToExecute))
+	for i, deps := range dependencies {
+		remaining[i] = len(deps)
+		for _, dep := range deps {
+			dependents[dep] = append(dependents[dep], i)
+		}
+	}
+	ready := []int{}
+	for i := range queriesToExecute {
+		if remaining[i] == 0 {
+			ready = append(ready, i)
+		}
+	}
+
+	results := make(chan dagResult, len(queriesToExecute))
+	started := make([]bool, len(queriesToExecute))
+	summary := &executionSummary{}
+	running := 0
+	var errs error
+
+	for {
+		// start ready queries in order while there's capacity and nothing failed
+		for errs == nil && ctx.Err() == nil && running < concurrency && len(ready) > 0 {
+			i := ready[0]
+			ready = ready[1:]
+			started[i] = true
+			running++
+			l.Info(fmt.Sprintf("processing query %d of %d", i+1, len(queriesToExecute)))
+			executeFn := c.ExecuteFn(i + 1)
+			go func(i int) {
+				results <- dagResult{index: i, err: executeFn(ctx, queriesToExecute[i], additionalHints)}
+			}(i)
+		}
+		if running == 0 {
+			break
+		}
+
+		result := <-results
+		running--
+		if errors.Is(result.err, client.ErrTerminated) { // running instance is terminated on cancellation
+			summary.add(result.index+1, nil, true)
+			continue
+		}
+		summary.add(result.index+1, result.err, false)
+		if result.err != nil {
+			errs = e.Join(errs, errors.WithStack(result.err))
+			continue
+		}
+		for _, dependent := range dependents[result.index] {
+			remaining[dependent]--
+			if remaining[dependent] == 0 {
+				ready = append(ready, dependent)
+			}
+		}
+		slices.Sort(ready)
+	}
+
+	// queries never started are cancelled
+	for i := range queriesToExecute {
+		if !started[i] {
+			summary.add(i+1, nil, true)
+		}
+	}
+
+	l.Info("all queries have been processed")
+	l.Info(fmt.Sprintf("execution summary: %s", summary))
+	if errs == nil && ctx.Err() != nil {
+		errs = context.Cause(ctx)
+	}
+	if errs != nil {
+		return errors.Wrapf(errs, "execution summary: %s", summary)
+	}
+	return nil
+}
@@ -0,0 +1,147 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/goto/transformers/mc2mc/internal/client"
+	"github.com/goto/transformers/mc2mc/internal/logger"
+)
+
+func TestExecuteDAG(t *testing.T) {
+	queries := func(n int) []string {
+		queriesToExecute := make([]string, n)
+		for i := range queriesToExecute {
+			queriesToExecute[i] = fmt.Sprintf("select %d", i+1)
+		}
+		return queriesToExecute
+	}
+
+	t.Run("starts query only after its dependencies are succeeded", func(t *testing.T) {
+		mu := sync.Mutex{}
+		finished := map[int]bool{}
+		finishedOnStart := map[int][]int{}
+		executor := &fakeExecutor{fn: func(ctx context.Context, id int, query string) error {
+			mu.Lock()
+			for i := 1; i <= 4; i++ {
+				if finished[i] {
+					finishedOnStart[id] = append(finishedOnStart[id], i)
+				}
+			}
+			mu.Unlock()
+			time.Sleep(time.Millisecond)
+			mu.Lock()
+			finished[id] = true
+			mu.Unlock()
+			return nil
+		}}
+		dependencies := [][]int{{}, {0}, {0}, {1, 2}}
+
+		err := executeDAG(context.Background(), logger.NewDefaultLogger(), executor, 4, queries(4), dependencies, nil)
+		assert.NoError(t, err)
+		assert.Len(t, executor.executed, 4)
+		assert.Empty(t, finishedOnStart[1])
+		assert.Contains(t, finishedOnStart[2], 1)
+		assert.Contains(t, finishedOnStart[3], 1)
+		assert.Subset(t, finishedOnStart[4], []int{1, 2, 3})
+	})
+	t.Run("executes independent queries concurrently while dependent one waits", func(t *testing.T) {
+		started := make(chan struct{})
+		finished := atomic.Bool{}
+		startedAfterDependency := false
+		executor := &fakeExecutor{fn: func(ctx context.Context, id int, query string) error {
+			switch id {
+			case 1:
+				<-started // blocks until the independent query is started
+				finished.Store(true)
+			case 2:
+				close(started)
+			case 3:
+				startedAfterDependency = finished.Load()
+			}
+			return nil
+		}}
+		dependencies := [][]int{{}, {}, {0}}
+
+		err := executeDAG(context.Background(), logger.NewDefaultLogger(), executor, 3, queries(3), dependencies, nil)
+		assert.NoError(t, err)
+		assert.True(t, startedAfterDependency)
+	})
+	t.Run("bounds running queries by the concurrency", func(t *testing.T) {
+		running := atomic.Int32{}
+		maxRunning := atomic.Int32{}
+		executor := &fakeExecutor{fn: func(ctx context.Context, id int, query string) error {
+			n := running.Add(1)
+			for {
+				current := maxRunning.Load()
+				if n <= current || maxRunning.CompareAndSwap(current, n) {
+					break
+				}
+			}
+			time.Sleep(5 * time.Millisecond)
+			running.Add(-1)
+			return nil
+		}}
+		dependencies := [][]int{{}, {}, {}, {}, {}, {}}
+
+		err := executeDAG(context.Background(), logger.NewDefaultLogger(), executor, 2, queries(6), dependencies, nil)
+		assert.NoError(t, err)
+		assert.Len(t, executor.executed, 6)
+		assert.LessOrEqual(t, maxRunning.Load(), int32(2))
+	})
+	t.Run("waits running queries and starts no query after failure", func(t *testing.T) {
+		started := make(chan struct{})
+		failed := make(chan struct{})
+		executor := &fakeExecutor{fn: func(ctx context.Context, id int, query string) error {
+			switch id {
+			case 1:
+				<-started
+				close(failed)
+				return errors.New("failed 1")
+			default:
+				close(started)
+				<-failed
+				return errors.New("failed 2")
+			}
+		}}
+		dependencies := [][]int{{}, {}, {}}
+
+		err := executeDAG(context.Background(), logger.NewDefaultLogger(), executor, 2, queries(3), dependencies, nil)
+		assert.ErrorContains(t, err, "failed 1")
+		assert.ErrorContains(t, err, "failed 2")
+		assert.ErrorContains(t, err, "execution summary: succeeded: [], failed: [1 2], cancelled: [3]")
+		assert.NotContains(t, executor.executed, 3)
+	})
+	t.Run("never starts query depending on failed one", func(t *testing.T) {
+		executor := &fakeExecutor{fn: func(ctx context.Context, id int, query string) error {
+			if id == 1 {
+				return errors.New("failed 1")
+			}
+			return nil
+		}}
+		dependencies := [][]int{{}, {0}}
+
+		err := executeDAG(context.Background(), logger.NewDefaultLogger(), executor, 2, queries(2), dependencies, nil)
+		assert.ErrorContains(t, err, "execution summary: succeeded: [], failed: [1], cancelled: [2]")
+		assert.Equal(t, map[int]string{1: "select 1"}, executor.executed)
+	})
+	t.Run("counts terminated queries as cancelled and returns cancellation cause", func(t *testing.T) {
+		ctx, cancel := context.WithCancelCause(context.Background())
+		executor := &fakeExecutor{fn: func(ctx context.Context, id int, query string) error {
+			cancel(errors.New("signal: interrupt"))
+			return fmt.Errorf("%w: %w", client.ErrTerminated, context.Cause(ctx))
+		}}
+		dependencies := [][]int{{}, {}}
+
+		err := executeDAG(ctx, logger.NewDefaultLogger(), executor, 1, queries(2), dependencies, nil)
+		assert.ErrorContains(t, err, "signal: interrupt")
+		assert.ErrorContains(t, err, "execution summary: succeeded: [], failed: [], cancelled: [1 2]")
+	})
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/pkg/errors"
+)
+
+// failurePolicy decides when the remaining queries are cancelled after failures,
+// maxFailures 0 means every query is executed regardless of failures
+type failurePolicy struct {
+	maxFailures int
+}
+
+// parseFailurePolicy parses continue, fail-fast or max-failures=N
+func parseFailurePolicy(policy string) (failurePolicy, error) {
+	policy = strings.ToLower(strings.TrimSpace(policy))
+	switch policy {
+	case "", "continue":
+		return failurePolicy{}, nil
+	case "fail-fast":
+		return failurePolicy{maxFailures: 1}, nil
+	}
+	if value, ok := strings.CutPrefix(policy, "max-failures="); ok {
+		maxFailures, err := strconv.Atoi(value)
+		if err == nil && maxFailures > 0 {
+			return failurePolicy{maxFailures: maxFailures}, nil
+		}
+	}
+	return failurePolicy{}, errors.Errorf("not supported failure policy: %s", policy)
+}
+
+// isReached returns true if the remaining queries must be cancelled
+func (p failurePolicy) isReached(failures int) bool {
+	return p.maxFailures > 0 && failures >= p.maxFailures
+}
+
+// executionSummary tracks the result of every sequence id of a concurrent execution
+type executionSummary struct {
+	mu        sync.Mutex
+	succeeded []int
+	failed    []int
+	cancelled []int
+}
+
+func (s *executionSummary) add(id int, err error, cancelled bool) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	switch {
+	case err != nil:
+		s.failed = append(s.failed, id)
+	case cancelled:
+		s.cancelled = append(s.cancelled, id)
+	default:
+		s.succeeded = append(s.succeeded, id)
+	}
+	return len(s.failed)
+}
+
+func (s *executionSummary) String() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	slices.Sort(s.succeeded)
+	slices.Sort(s.failed)
+	slices.Sort(s.cancelled)
+	return fmt.Sprintf("succeeded: %v, failed: %v, cancelled: %v", s.succeeded, s.failed, s.cancelled)
+}
@@ -0,0 +1,162 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/goto/transformers/mc2mc/internal/client"
+	"github.com/goto/transformers/mc2mc/internal/logger"
+)
+
+func TestParseFailurePolicy(t *testing.T) {
+	t.Run("returns policy which never cancels for continue", func(t *testing.T) {
+		for _, value := range []string{"", "continue", " CONTINUE "} {
+			policy, err := parseFailurePolicy(value)
+			assert.NoError(t, err)
+			assert.False(t, policy.isReached(100))
+		}
+	})
+	t.Run("returns policy which cancels after first failure for fail-fast", func(t *testing.T) {
+		policy, err := parseFailurePolicy("fail-fast")
+		assert.NoError(t, err)
+		assert.False(t, policy.isReached(0))
+		assert.True(t, policy.isReached(1))
+	})
+	t.Run("returns policy which cancels after the given failures", func(t *testing.T) {
+		policy, err := parseFailurePolicy("max-failures=3")
+		assert.NoError(t, err)
+		assert.False(t, policy.isReached(2))
+		assert.True(t, policy.isReached(3))
+	})
+	t.Run("returns error for invalid policy", func(t *testing.T) {
+		for _, value := range []string{"max-failures=0", "max-failures=-1", "max-failures=x", "max-failures=", "stop"} {
+			_, err := parseFailurePolicy(value)
+			assert.ErrorContains(t, err, "not supported failure policy", value)
+		}
+	})
+}
+
+func TestExecutionSummary(t *testing.T) {
+	t.Run("counts failures only and reports sorted sequence ids", func(t *testing.T) {
+		summary := &executionSummary{}
+		assert.Equal(t, 0, summary.add(3, nil, false))
+		assert.Equal(t, 1, summary.add(2, errors.New("failed"), false))
+		assert.Equal(t, 1, summary.add(5, nil, true))
+		assert.Equal(t, 2, summary.add(1, errors.New("failed"), true), "failure is not counted as cancelled")
+		assert.Equal(t, 2, summary.add(4, nil, true))
+		assert.Equal(t, "succeeded: [3], failed: [1 2], cancelled: [4 5]", summary.String())
+	})
+}
+
+func TestExecuteConcurrently(t *testing.T) {
+	queries := func(n int) []string {
+		queriesToExecute := make([]string, n)
+		for i := range queriesToExecute {
+			queriesToExecute[i] = fmt.Sprintf("select %d", i+1)
+		}
+		return queriesToExecute
+	}
+	// started returns channels closed once the query of the sequence id is started
+	started := func(n int) map[int]chan struct{} {
+		channels := map[int]chan struct{}{}
+		for id := 1; id <= n; id++ {
+			channels[id] = make(chan struct{})
+		}
+		return channels
+	}
+	// terminated simulates in-flight instance terminated on cancellation
+	terminated := func(ctx context.Context) error {
+		<-ctx.Done()
+		return fmt.Errorf("%w: %w", client.ErrTerminated, context.Cause(ctx))
+	}
+
+	t.Run("cancels in-flight and unscheduled queries after first failure for fail-fast", func(t *testing.T) {
+		ch := started(2)
+		executor := &fakeExecutor{fn: func(ctx context.Context, id int, query string) error {
+			close(ch[id])
+			switch id {
+			case 1:
+				<-ch[2]
+				return errors.New("failed 1")
+			default:
+				return terminated(ctx)
+			}
+		}}
+		policy, _ := parseFailurePolicy("fail-fast")
+
+		err := executeConcurrently(context.Background(), logger.NewDefaultLogger(), executor, 2, policy, 0, queries(5), nil)
+		assert.ErrorContains(t, err, "failed 1")
+		assert.ErrorContains(t, err, "execution summary: succeeded: [], failed: [1], cancelled: [2 3 4 5]")
+		assert.Equal(t, map[int]string{1: "select 1", 2: "select 2"}, executor.executed)
+	})
+	t.Run("cancels in-flight and unscheduled queries after the given failures", func(t *testing.T) {
+		ch := started(5)
+		executor := &fakeExecutor{fn: func(ctx context.Context, id int, query string) error {
+			id -= 10 // sequence ids start after the offset
+			close(ch[id])
+			switch id {
+			case 1:
+				return nil
+			case 2:
+				return errors.New("failed 2")
+			case 4:
+				<-ch[5]
+				return errors.New("failed 4")
+			default:
+				return terminated(ctx)
+			}
+		}}
+		policy, _ := parseFailurePolicy("max-failures=2")
+
+		err := executeConcurrently(context.Background(), logger.NewDefaultLogger(), executor, 3, policy, 10, queries(6), nil)
+		assert.ErrorContains(t, err, "failed 2")
+		assert.ErrorContains(t, err, "failed 4")
+		assert.ErrorContains(t, err, "execution summary: succeeded: [11], failed: [12 14], cancelled: [13 15 16]")
+		assert.Len(t, executor.executed, 5)
+		assert.NotContains(t, executor.executed, 16)
+	})
+	t.Run("counts query succeeded while cancelling as succeeded", func(t *testing.T) {
+		ch := started(2)
+		executor := &fakeExecutor{fn: func(ctx context.Context, id int, query string) error {
+			close(ch[id])
+			if id == 1 {
+				<-ch[2]
+				return errors.New("failed 1")
+			}
+			<-ctx.Done()
+			return nil
+		}}
+		policy, _ := parseFailurePolicy("fail-fast")
+
+		err := executeConcurrently(context.Background(), logger.NewDefaultLogger(), executor, 2, policy, 0, queries(3), nil)
+		assert.ErrorContains(t, err, "execution summary: succeeded: [2], failed: [1], cancelled: [3]")
+	})
+	t.Run("returns cancellation cause when parent context is cancelled", func(t *testing.T) {
+		ctx, cancel := context.WithCancelCause(context.Background())
+		executor := &fakeExecutor{fn: func(ctx context.Context, id int, query string) error {
+			cancel(errors.New("signal: interrupt"))
+			return terminated(ctx)
+		}}
+
+		err := executeConcurrently(ctx, logger.NewDefaultLogger(), executor, 1, failurePolicy{}, 0, queries(2), nil)
+		assert.ErrorContains(t, err, "signal: interrupt")
+		assert.ErrorContains(t, err, "execution summary: succeeded: [], failed: [], cancelled: [1 2]")
+		assert.Len(t, executor.executed, 1)
+	})
+	t.Run("executes every query regardless of failures for continue", func(t *testing.T) {
+		executor := &fakeExecutor{fn: func(ctx context.Context, id int, query string) error {
+			if id%2 == 0 {
+				return fmt.Errorf("failed %d", id)
+			}
+			return nil
+		}}
+
+		err := executeConcurrently(context.Background(), logger.NewDefaultLogger(), executor, 2, failurePolicy{}, 0, queries(5), nil)
+		assert.ErrorContains(t, err, "execution summary: succeeded: [1 3 5], failed: [2 4], cancelled: []")
+		assert.Len(t, executor.executed, 5)
+	})
+}
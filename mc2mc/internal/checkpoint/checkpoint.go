@@ -0,0 +1,88 @@
+package checkpoint
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// Status is the status of an executed statement
+type Status string
+
+const (
+	StatusRunning   Status = "RUNNING"
+	StatusSucceeded Status = "SUCCEEDED"
+	StatusFailed    Status = "FAILED"
+)
+
+// Checkpoint is the execution state of a statement of a run,
+// the statement is identified by its sequence and query hash within the run
+type Checkpoint struct {
+	RunKey     string    `json:"run_key"`
+	Sequence   int       `json:"sequence"`
+	QueryHash  string    `json:"query_hash"`
+	InstanceID string    `json:"instance_id"`
+	Status     Status    `json:"status"`
+	UpdatedAt  time.Time `json:"updated_at"`
+}
+
+// Store persists the checkpoints of the runs
+type Store interface {
+	// Load returns the latest checkpoint of each statement of the given run
+	Load(ctx context.Context, runKey string) ([]Checkpoint, error)
+	// Save records the given checkpoint
+	Save(ctx context.Context, checkpoint Checkpoint) error
+}
+
+// RunKey returns the key of the run of a job for the given window, every part must be
+// the same on each attempt of the run so it's required
+func RunKey(jobName, dstart, dend string) (string, error) {
+	if jobName == "" || dstart == "" || dend == "" {
+		return "", errors.New("JOB_NAME, DSTART and DEND are required to identify the run")
+	}
+	return hash(strings.Join([]string{jobName, dstart, dend}, "|")), nil
+}
+
+// QueryHash returns the hash of the given query, so a changed query is not resumed
+func QueryHash(query string) string {
+	return hash(strings.TrimSpace(query))
+}
+
+// Find returns the checkpoint of the statement with the given sequence and query hash
+func Find(checkpoints []Checkpoint, sequence int, queryHash string) (Checkpoint, bool) {
+	for _, checkpoint := range checkpoints {
+		if checkpoint.Sequence == sequence && checkpoint.QueryHash == queryHash {
+			return checkpoint, true
+		}
+	}
+	return Checkpoint{}, false
+}
+
+// latest keeps the latest checkpoint of each statement, the given checkpoints are in recorded order
+func latest(checkpoints []Checkpoint) []Checkpoint {
+	type statement struct {
+		sequence  int
+		queryHash string
+	}
+	indexes := map[statement]int{}
+	result := []Checkpoint{}
+	for _, checkpoint := range checkpoints {
+		key := statement{checkpoint.Sequence, checkpoint.QueryHash}
+		if i, ok := indexes[key]; ok {
+			result[i] = checkpoint
+			continue
+		}
+		indexes[key] = len(result)
+		result = append(result, checkpoint)
+	}
+	return result
+}
+
+func hash(s string) string {
+	sum := sha256.Sum256([]byte(s))
+	return hex.EncodeToString(sum[:])
+}
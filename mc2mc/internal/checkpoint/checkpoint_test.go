@@ -0,0 +1,63 @@
+package checkpoint
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRunKey(t *testing.T) {
+	t.Run("returns the same key for the same run", func(t *testing.T) {
+		key1, err := RunKey("job", "2024-01-01T00:00:00Z", "2024-01-02T00:00:00Z")
+		assert.NoError(t, err)
+		key2, err := RunKey("job", "2024-01-01T00:00:00Z", "2024-01-02T00:00:00Z")
+		assert.NoError(t, err)
+		assert.Equal(t, key1, key2)
+	})
+	t.Run("returns different key for another window", func(t *testing.T) {
+		key1, err := RunKey("job", "2024-01-01T00:00:00Z", "2024-01-02T00:00:00Z")
+		assert.NoError(t, err)
+		key2, err := RunKey("job", "2024-01-02T00:00:00Z", "2024-01-03T00:00:00Z")
+		assert.NoError(t, err)
+		assert.NotEqual(t, key1, key2)
+	})
+	t.Run("returns error when job name is missing", func(t *testing.T) {
+		key, err := RunKey("", "2024-01-01T00:00:00Z", "2024-01-02T00:00:00Z")
+		assert.ErrorContains(t, err, "JOB_NAME, DSTART and DEND are required")
+		assert.Empty(t, key)
+	})
+}
+
+func TestFind(t *testing.T) {
+	checkpoints := []Checkpoint{
+		{Sequence: 1, QueryHash: "a", Status: StatusSucceeded},
+		{Sequence: 2, QueryHash: "b", Status: StatusFailed},
+	}
+
+	t.Run("returns checkpoint with the same sequence and query hash", func(t *testing.T) {
+		checkpoint, ok := Find(checkpoints, 2, "b")
+		assert.True(t, ok)
+		assert.Equal(t, StatusFailed, checkpoint.Status)
+	})
+	t.Run("returns nothing when query of the sequence is changed", func(t *testing.T) {
+		_, ok := Find(checkpoints, 1, "b")
+		assert.False(t, ok)
+	})
+}
+
+func TestLatest(t *testing.T) {
+	t.Run("keeps the last recorded checkpoint of each statement in first recorded order", func(t *testing.T) {
+		checkpoints := latest([]Checkpoint{
+			{Sequence: 1, QueryHash: "a", Status: StatusRunning},
+			{Sequence: 2, QueryHash: "b", Status: StatusRunning},
+			{Sequence: 1, QueryHash: "a", Status: StatusSucceeded},
+			{Sequence: 1, QueryHash: "c", Status: StatusRunning},
+			{Sequence: 2, QueryHash: "b", Status: StatusFailed},
+		})
+		assert.Equal(t, []Checkpoint{
+			{Sequence: 1, QueryHash: "a", Status: StatusSucceeded},
+			{Sequence: 2, QueryHash: "b", Status: StatusFailed},
+			{Sequence: 1, QueryHash: "c", Status: StatusRunning},
+		}, checkpoints)
+	})
+}
@@ -0,0 +1,83 @@
+package checkpoint
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/pkg/errors"
+)
+
+// fileStore stores checkpoints as json lines in a local file
+type fileStore struct {
+	mu   sync.Mutex
+	path string
+}
+
+// NewFileStore creates a checkpoint store backed by the given local file
+func NewFileStore(path string) (*fileStore, error) {
+	if path == "" {
+		return nil, errors.New("checkpoint file path is required")
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return nil, errors.WithStack(err)
+	}
+	return &fileStore{path: path}, nil
+}
+
+// Load returns the latest checkpoint of each statement of the given run
+func (s *fileStore) Load(_ context.Context, runKey string) ([]Checkpoint, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	f, err := os.Open(s.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	defer f.Close()
+
+	checkpoints := []Checkpoint{}
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+		var checkpoint Checkpoint
+		if err := json.Unmarshal(scanner.Bytes(), &checkpoint); err != nil {
+			return nil, errors.WithStack(err)
+		}
+		if checkpoint.RunKey == runKey {
+			checkpoints = append(checkpoints, checkpoint)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, errors.WithStack(err)
+	}
+	return latest(checkpoints), nil
+}
+
+// Save appends the given checkpoint to the file
+func (s *fileStore) Save(_ context.Context, checkpoint Checkpoint) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	raw, err := json.Marshal(checkpoint)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	f, err := os.OpenFile(s.path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o644)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	if _, err := f.Write(append(raw, '\n')); err != nil {
+		f.Close()
+		return errors.WithStack(err)
+	}
+	return errors.WithStack(f.Close())
+}
@@ -0,0 +1,54 @@
+package checkpoint_test
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/goto/transformers/mc2mc/internal/checkpoint"
+)
+
+func TestFileStore(t *testing.T) {
+	updatedAt := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	t.Run("returns error when file path is empty", func(t *testing.T) {
+		store, err := checkpoint.NewFileStore("")
+		assert.ErrorContains(t, err, "checkpoint file path is required")
+		assert.Nil(t, store)
+	})
+	t.Run("returns no checkpoint when file doesn't exist", func(t *testing.T) {
+		store, err := checkpoint.NewFileStore(filepath.Join(t.TempDir(), "out", "checkpoint.jsonl"))
+		assert.NoError(t, err)
+
+		checkpoints, err := store.Load(context.Background(), "run")
+		assert.NoError(t, err)
+		assert.Empty(t, checkpoints)
+	})
+	t.Run("returns latest saved checkpoints of the run", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "checkpoint.jsonl")
+		store, err := checkpoint.NewFileStore(path)
+		assert.NoError(t, err)
+
+		for _, c := range []checkpoint.Checkpoint{
+			{RunKey: "run", Sequence: 1, QueryHash: "a", InstanceID: "i1", Status: checkpoint.StatusRunning, UpdatedAt: updatedAt},
+			{RunKey: "other", Sequence: 1, QueryHash: "a", InstanceID: "i2", Status: checkpoint.StatusSucceeded, UpdatedAt: updatedAt},
+			{RunKey: "run", Sequence: 1, QueryHash: "a", InstanceID: "i1", Status: checkpoint.StatusSucceeded, UpdatedAt: updatedAt},
+			{RunKey: "run", Sequence: 2, QueryHash: "b", InstanceID: "i3", Status: checkpoint.StatusFailed, UpdatedAt: updatedAt},
+		} {
+			assert.NoError(t, store.Save(context.Background(), c))
+		}
+
+		// checkpoints are read back by another store of the next attempt
+		store, err = checkpoint.NewFileStore(path)
+		assert.NoError(t, err)
+		checkpoints, err := store.Load(context.Background(), "run")
+		assert.NoError(t, err)
+		assert.Equal(t, []checkpoint.Checkpoint{
+			{RunKey: "run", Sequence: 1, QueryHash: "a", InstanceID: "i1", Status: checkpoint.StatusSucceeded, UpdatedAt: updatedAt},
+			{RunKey: "run", Sequence: 2, QueryHash: "b", InstanceID: "i3", Status: checkpoint.StatusFailed, UpdatedAt: updatedAt},
+		}, checkpoints)
+	})
+}
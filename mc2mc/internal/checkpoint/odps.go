@@ -0,0 +1,89 @@
+package checkpoint
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// sqlClient executes queries on ODPS and reads their results
+type sqlClient interface {
+	ExecSQL(ctx context.Context, query string, hints map[string]string) error
+	QueryRows(ctx context.Context, query string) ([][]string, error)
+}
+
+// odpsStore stores checkpoints in an ODPS table
+type odpsStore struct {
+	client  sqlClient
+	tableID string
+}
+
+// NewODPSStore creates a checkpoint store backed by the given ODPS table,
+// the table is created if it doesn't exist
+func NewODPSStore(ctx context.Context, client sqlClient, tableID string) (*odpsStore, error) {
+	if tableID == "" {
+		return nil, errors.New("checkpoint table is required")
+	}
+	createQuery := fmt.Sprintf(`CREATE TABLE IF NOT EXISTS %s (
+  run_key STRING,
+  sequence BIGINT,
+  query_hash STRING,
+  instance_id STRING,
+  status STRING,
+  updated_at DATETIME
+)
+LIFECYCLE 30
+;`, tableID)
+	if err := client.ExecSQL(ctx, createQuery, nil); err != nil {
+		return nil, errors.WithStack(err)
+	}
+	return &odpsStore{client: client, tableID: tableID}, nil
+}
+
+// Load returns the latest checkpoint of each statement of the given run
+func (s *odpsStore) Load(ctx context.Context, runKey string) ([]Checkpoint, error) {
+	loadQuery := fmt.Sprintf("SELECT sequence, query_hash, instance_id, status, updated_at FROM %s WHERE run_key = %s ORDER BY updated_at, IF(status = 'RUNNING', 0, 1) LIMIT 10000;", s.tableID, quote(runKey))
+	rows, err := s.client.QueryRows(ctx, loadQuery)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	checkpoints := make([]Checkpoint, 0, len(rows))
+	for _, row := range rows {
+		if len(row) != 5 {
+			return nil, errors.Errorf("unexpected checkpoint row: %v", row)
+		}
+		sequence, err := strconv.Atoi(row[0])
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+		updatedAt, err := time.Parse(time.DateTime, row[4])
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+		checkpoints = append(checkpoints, Checkpoint{
+			RunKey:     runKey,
+			Sequence:   sequence,
+			QueryHash:  row[1],
+			InstanceID: row[2],
+			Status:     Status(row[3]),
+			UpdatedAt:  updatedAt,
+		})
+	}
+	return latest(checkpoints), nil
+}
+
+// Save inserts the given checkpoint into the table
+func (s *odpsStore) Save(ctx context.Context, checkpoint Checkpoint) error {
+	saveQuery := fmt.Sprintf("INSERT INTO TABLE %s VALUES (%s, %d, %s, %s, %s, DATETIME %s);", s.tableID,
+		quote(checkpoint.RunKey), checkpoint.Sequence, quote(checkpoint.QueryHash),
+		quote(checkpoint.InstanceID), quote(string(checkpoint.Status)), quote(checkpoint.UpdatedAt.UTC().Format(time.DateTime)))
+	return errors.WithStack(s.client.ExecSQL(ctx, saveQuery, nil))
+}
+
+func quote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", "\\'") + "'"
+}
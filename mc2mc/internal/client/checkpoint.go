@@ -0,0 +1,74 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/goto/transformers/mc2mc/internal/checkpoint"
+)
+
+// resume returns true if the statement with the given sequence is done by the previous attempt,
+// either it's succeeded or its still running instance is reattached until it succeeds.
+// Statements of sequence 0 are auxiliary queries so they're not checkpointed.
+func (c *Client) resume(ctx context.Context, id int, queryHash string) (bool, error) {
+	if c.checkpointStore == nil || id <= 0 {
+		return false, nil
+	}
+	cp, ok := checkpoint.Find(c.checkpoints, id, queryHash)
+	if !ok {
+		return false, nil
+	}
+
+	switch cp.Status {
+	case checkpoint.StatusSucceeded:
+		c.logger.Info(fmt.Sprintf("[sequence: %d] skipped, it's succeeded by the previous attempt", id))
+		return true, nil
+	case checkpoint.StatusRunning:
+		if cp.InstanceID == "" {
+			return false, nil
+		}
+		c.logger.Info(fmt.Sprintf("[sequence: %d] reattaching to instance %s of the previous attempt", id, cp.InstanceID))
+		if err := c.OdpsClient.Reattach(ctx, cp.InstanceID); err != nil {
+			if errors.Is(err, ErrTerminated) {
+				return false, errors.WithStack(err)
+			}
+			c.logger.Warn(fmt.Sprintf("[sequence: %d] instance %s of the previous attempt is not succeeded, resubmitting: %s", id, cp.InstanceID, err))
+			return false, nil
+		}
+		c.saveCheckpoint(ctx, id, queryHash, cp.InstanceID, checkpoint.StatusSucceeded)
+		c.logger.Info(fmt.Sprintf("[sequence: %d] execution done", id))
+		return true, nil
+	default:
+		return false, nil
+	}
+}
+
+// DiscardCheckpoints discards the checkpoints of the previous attempts so every statement
+// is executed again, e.g. when the table written by the statements has been dropped
+func (c *Client) DiscardCheckpoints() {
+	if len(c.checkpoints) > 0 {
+		c.logger.Info(fmt.Sprintf("discarding %d checkpoints of the previous attempt", len(c.checkpoints)))
+	}
+	c.checkpoints = nil
+}
+
+// saveCheckpoint records the status of the statement, failing to record it doesn't fail the statement
+func (c *Client) saveCheckpoint(ctx context.Context, id int, queryHash, instanceID string, status checkpoint.Status) {
+	if c.checkpointStore == nil || id <= 0 {
+		return
+	}
+	err := c.checkpointStore.Save(context.WithoutCancel(ctx), checkpoint.Checkpoint{
+		RunKey:     c.runKey,
+		Sequence:   id,
+		QueryHash:  queryHash,
+		InstanceID: instanceID,
+		Status:     status,
+		UpdatedAt:  time.Now(),
+	})
+	if err != nil {
+		c.logger.Warn(fmt.Sprintf("[sequence: %d] failed to save checkpoint: %s", id, err))
+	}
+}
@@ -5,16 +5,28 @@ import (
 	e "errors"
 	"fmt"
 	"log/slog"
+	"time"
 
 	"github.com/pkg/errors"
+
+	"github.com/goto/transformers/mc2mc/internal/checkpoint"
 )
 
 const (
 	SqlScriptSequenceHint = "goto.sql.script.sequence"
+	SqlRunKeyHint         = "goto.sql.run.key"
 )
 
+// ErrTerminated is returned when the instance is terminated since the context is cancelled,
+// it's joined with the cancellation cause
+var ErrTerminated = e.New("instance is terminated on cancellation")
+
 type OdpsClient interface {
 	ExecSQL(ctx context.Context, query string, hints map[string]string) error
+	QueryRows(ctx context.Context, query string) ([][]string, error)
+	Reattach(ctx context.Context, instanceID string) error
+	RunningInstances(ctx context.Context, runKey string, since time.Time) ([]RunningInstance, error)
+	Terminate(instanceID string) error
 	SetDefaultProject(project string)
 	SetLogViewRetentionInDays(days int)
 	SetDryRun(dryRun bool)
@@ -28,6 +40,12 @@ type Client struct {
 	appCtx      context.Context
 	logger      *slog.Logger
 	shutdownFns []func() error
+
+	checkpointStore checkpoint.Store
+	runKey          string
+	checkpoints     []checkpoint.Checkpoint // checkpoints of the previous attempts to resume
+	// running instances of the previous attempts to reattach to
+	runningInstances []RunningInstance
 }
 
 func NewClient(ctx context.Context, setupFns ...SetupFn) (*Client, error) {
@@ -55,17 +73,37 @@ func (c *Client) Close() error {
 func (c *Client) ExecuteFn(id int) func(context.Context, string, map[string]string) error {
 	return func(ctx context.Context, query string, additionalHints map[string]string) error {
 		c.logger.Info(fmt.Sprintf("[sequence: %d] query to execute:\n%s", id, query))
-		// Create local copy of additionalHints with sequence hint
-		hints := make(map[string]string, len(additionalHints)+1)
+		// Create local copy of additionalHints with sequence and run key hints
+		hints := make(map[string]string, len(additionalHints)+2)
 		for k, v := range additionalHints {
 			hints[k] = v
 		}
 		hints[SqlScriptSequenceHint] = fmt.Sprintf("%d", id)
+		if c.runKey != "" {
+			hints[SqlRunKeyHint] = c.runKey
+		}
+
+		// skip the statement if it's done by the previous attempt
+		queryHash := checkpoint.QueryHash(query)
+		if done, err := c.resume(ctx, id, queryHash); err != nil || done {
+			return errors.WithStack(err)
+		}
+		if done, err := c.reattachRunning(ctx, id, queryHash); err != nil || done {
+			return errors.WithStack(err)
+		}
 
 		// execute query with odps client
-		if err := c.OdpsClient.ExecSQL(ctx, query, hints); err != nil {
+		execCtx := ctx
+		if c.checkpointStore != nil && id > 0 {
+			execCtx = withSubmitHook(ctx, func(instanceID string) {
+				c.saveCheckpoint(ctx, id, queryHash, instanceID, checkpoint.StatusRunning)
+			})
+		}
+		if err := c.OdpsClient.ExecSQL(execCtx, query, hints); err != nil {
+			c.saveCheckpoint(ctx, id, queryHash, "", checkpoint.StatusFailed)
 			return errors.WithStack(err)
 		}
+		c.saveCheckpoint(ctx, id, queryHash, "", checkpoint.StatusSucceeded)
 
 		c.logger.Info(fmt.Sprintf("[sequence: %d] execution done", id))
 		return nil
@@ -2,16 +2,23 @@ package client
 
 import (
 	"context"
+	"encoding/csv"
+	"encoding/json"
+	"encoding/xml"
 	e "errors"
 	"fmt"
 	"log/slog"
 	"net/url"
+	"strconv"
 	"strings"
 	"time"
 
 	"github.com/aliyun/aliyun-odps-go-sdk/odps"
+	"github.com/aliyun/aliyun-odps-go-sdk/odps/common"
 	"github.com/aliyun/aliyun-odps-go-sdk/odps/options"
 	"github.com/pkg/errors"
+
+	"github.com/goto/transformers/mc2mc/pkg/query"
 )
 
 type odpsClient struct {
@@ -50,6 +57,9 @@ func (c *odpsClient) ExecSQL(ctx context.Context, query string, additionalHints
 	if err != nil {
 		return errors.WithStack(err)
 	}
+	if onSubmit, ok := ctx.Value(submitHookKey{}).(func(string)); ok {
+		onSubmit(taskIns.Id())
+	}
 
 	// generate log view
 	url, err := c.generateLogView(taskIns)
@@ -59,7 +69,78 @@ func (c *odpsClient) ExecSQL(ctx context.Context, query string, additionalHints
 	}
 	c.logger.Info(fmt.Sprintf("taskId: %s, log view: %s , hints: (%s)", taskIns.Id(), url, getHintsString(hints)))
 
-	// wait execution success
+	return errors.WithStack(c.waitOrTerminate(ctx, taskIns))
+}
+
+// Reattach waits for the already submitted instance with the given id to finish
+// instead of submitting the query again, the instance is terminated when context is cancelled.
+func (c *odpsClient) Reattach(ctx context.Context, instanceID string) error {
+	taskIns := c.client.Instances().Get(instanceID)
+	if err := c.retry(taskIns.Load); err != nil {
+		return errors.WithStack(err)
+	}
+	c.logger.Info(fmt.Sprintf("reattaching to task instance %s with status: %s", taskIns.Id(), taskIns.Status()))
+	return errors.WithStack(c.waitOrTerminate(ctx, taskIns))
+}
+
+// RunningInstances returns the running instances submitted by the current account since the given time
+// which are tagged with the given run key
+func (c *odpsClient) RunningInstances(_ context.Context, runKey string, since time.Time) ([]RunningInstance, error) {
+	return findRunningInstances(c.logger, c, runKey, since)
+}
+
+// listRunningInstances returns the running instances submitted by the current account since the given time
+func (c *odpsClient) listRunningInstances(since time.Time) ([]instanceSummary, error) {
+	instances := []instanceSummary{}
+	err := c.retry(func() error {
+		instances = instances[:0]
+		return c.client.Instances().List(func(ins *odps.Instance) {
+			instances = append(instances, instanceSummary{ID: ins.Id(), StartTime: ins.StartTime()})
+		}, odps.InstanceFilter.Status(odps.InstanceRunning), odps.InstanceFilter.OnlyOwner(), odps.InstanceFilter.TimeRange(since, time.Now()))
+	})
+	return instances, errors.WithStack(err)
+}
+
+// Terminate terminates the instance with the given id
+func (c *odpsClient) Terminate(instanceID string) error {
+	return errors.WithStack(c.terminate(c.client.Instances().Get(instanceID)))
+}
+
+// getInstanceSource returns the hints and the query of the sql task of the given instance
+func (c *odpsClient) getInstanceSource(instanceID string) (map[string]string, string, error) {
+	type sourceModel struct {
+		XMLName xml.Name `xml:"Instance"`
+		Tasks   []struct {
+			Query  string
+			Config []common.Property `xml:"Config>Property"`
+		} `xml:"Job>Tasks>SQL"`
+	}
+
+	var model sourceModel
+	rb := common.ResourceBuilder{ProjectName: c.client.DefaultProjectName()}
+	restClient := c.client.RestClient()
+	if err := restClient.GetWithModel(rb.Instance(instanceID), url.Values{"source": []string{""}}, &model); err != nil {
+		return nil, "", errors.WithStack(err)
+	}
+	if len(model.Tasks) == 0 {
+		return nil, "", errors.Errorf("instance %s has no sql task", instanceID)
+	}
+
+	hints := map[string]string{}
+	for _, property := range model.Tasks[0].Config {
+		if property.Name != "settings" {
+			continue
+		}
+		if err := json.Unmarshal([]byte(property.Value), &hints); err != nil {
+			return nil, "", errors.WithStack(err)
+		}
+	}
+	return hints, model.Tasks[0].Query, nil
+}
+
+// waitOrTerminate waits for the task instance to finish,
+// it's terminated when context is cancelled or it fails
+func (c *odpsClient) waitOrTerminate(ctx context.Context, taskIns *odps.Instance) error {
 	select {
 	case <-ctx.Done():
 		msg := "context canceled"
@@ -67,7 +148,8 @@ func (c *odpsClient) ExecSQL(ctx context.Context, query string, additionalHints
 			msg = fmt.Sprintf("%s: %s", msg, err.Error())
 		}
 		c.logger.Info(msg)
-		return errors.WithStack(c.terminate(taskIns))
+		err := e.Join(fmt.Errorf("%w: %w", ErrTerminated, context.Cause(ctx)), c.terminate(taskIns))
+		return errors.WithStack(err)
 	case err := <-c.wait(taskIns):
 		if err != nil {
 			c.logger.Error(fmt.Sprintf("task instance %s failed: %s", taskIns.Id(), err))
@@ -135,6 +217,88 @@ func (c *odpsClient) GetOrderedColumns(tableID string) ([]string, error) {
 	return columnNames, nil
 }
 
+// GetPartitionValues returns the partitions of the given table
+// in the format of name=value/name=value.
+func (c *odpsClient) GetPartitionValues(_ context.Context, tableID string) ([]string, error) {
+	var partitionValues []string
+	err := withTable(c.client, tableID, func(t *odps.Table) error {
+		var err error
+		partitionValues, err = t.GetPartitionValues()
+		return errors.WithStack(err)
+	})
+	return partitionValues, errors.WithStack(err)
+}
+
+// TableExists returns true if the given table exists
+func (c *odpsClient) TableExists(tableID string) (bool, error) {
+	var exists bool
+	err := withTable(c.client, tableID, func(t *odps.Table) error {
+		var err error
+		exists, err = t.Exists()
+		return errors.WithStack(err)
+	})
+	return exists, errors.WithStack(err)
+}
+
+// GetColumns returns the columns of the given table along with their types
+func (c *odpsClient) GetColumns(tableID string) ([]query.Column, error) {
+	table, err := getTable(c.client, tableID)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	var columns []query.Column
+	for _, column := range table.Schema().Columns {
+		columns = append(columns, query.Column{Name: sanitizeColumnName(column.Name), Type: column.Type.Name(), NotNull: column.NotNull})
+	}
+
+	return columns, nil
+}
+
+// QueryRows returns the result rows of the given select query without the header,
+// the result is limited to 10000 rows by ODPS.
+func (c *odpsClient) QueryRows(ctx context.Context, query string) ([][]string, error) {
+	taskIns, err := c.execSQLWithHintsAndPriority(query, nil)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	if err := c.waitOrTerminate(ctx, taskIns); err != nil {
+		return nil, errors.WithStack(err)
+	}
+	if ctx.Err() != nil {
+		return nil, errors.WithStack(context.Cause(ctx))
+	}
+
+	results, err := taskIns.GetResult()
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	if len(results) == 0 {
+		return nil, errors.Errorf("failed to get result from instance %s", taskIns.Id())
+	}
+	records, err := csv.NewReader(strings.NewReader(results[0].Content())).ReadAll()
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	if len(records) == 0 {
+		return nil, nil
+	}
+	return records[1:], nil // first record is the header
+}
+
+// CountRows returns the number of rows of the given table
+// by reading the result of a count query.
+func (c *odpsClient) CountRows(ctx context.Context, tableID string) (int64, error) {
+	rows, err := c.QueryRows(ctx, fmt.Sprintf("SELECT COUNT(*) FROM %s;", tableID))
+	if err != nil {
+		return 0, errors.WithStack(err)
+	}
+	if len(rows) == 0 || len(rows[0]) == 0 {
+		return 0, errors.Errorf("unexpected count result of table %s: %v", tableID, rows)
+	}
+	count, err := strconv.ParseInt(rows[0][0], 10, 64)
+	return count, errors.WithStack(err)
+}
+
 // execSQLWithHintsAndPriority executes the given query with hints and priority
 // ref: https://github.com/aliyun/aliyun-odps-go-sdk/blob/4d1188c6ac989acc9cacc9b3e2ed0f3901a3b3ef/odps/odps.go#L131
 func (c *odpsClient) execSQLWithHintsAndPriority(query string, hints map[string]string) (*odps.Instance, error) {
@@ -218,6 +382,14 @@ func (c *odpsClient) terminate(instance *odps.Instance) error {
 	return nil
 }
 
+// submitHookKey is the context key of the function called with the instance id once a query is submitted
+type submitHookKey struct{}
+
+// withSubmitHook returns a context which makes ExecSQL call fn with the id of the submitted instance
+func withSubmitHook(ctx context.Context, fn func(instanceID string)) context.Context {
+	return context.WithValue(ctx, submitHookKey{}, fn)
+}
+
 func addHints(additionalHints map[string]string, query string) map[string]string {
 	hints := make(map[string]string)
 	multisql := strings.Contains(query, ";")
@@ -237,6 +409,20 @@ func addHints(additionalHints map[string]string, query string) map[string]string
 
 // getTable returns the table with the given tableID
 func getTable(client *odps.Odps, tableID string) (*odps.Table, error) {
+	var table *odps.Table
+	err := withTable(client, tableID, func(t *odps.Table) error {
+		table = t
+		return errors.WithStack(t.Load())
+	})
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	return table, nil
+}
+
+// withTable calls fn with the table of the given tableID
+// while project and schema of the client are set to the table's
+func withTable(client *odps.Odps, tableID string, fn func(*odps.Table) error) error {
 	// save current project and schema
 	currProject := client.DefaultProjectName()
 	currSchema := client.CurrentSchemaName()
@@ -248,7 +434,7 @@ func getTable(client *odps.Odps, tableID string) (*odps.Table, error) {
 
 	splittedTableID := strings.Split(tableID, ".")
 	if len(splittedTableID) != 3 {
-		return nil, errors.Errorf("invalid tableID (tableID should be in format project.schema.table): %s", tableID)
+		return errors.Errorf("invalid tableID (tableID should be in format project.schema.table): %s", tableID)
 	}
 	project, schema, name := splittedTableID[0], splittedTableID[1], splittedTableID[2]
 
@@ -256,12 +442,7 @@ func getTable(client *odps.Odps, tableID string) (*odps.Table, error) {
 	client.SetDefaultProjectName(project)
 	client.SetCurrentSchemaName(schema)
 
-	// get table
-	table := client.Tables().Get(name)
-	if err := table.Load(); err != nil {
-		return nil, errors.WithStack(err)
-	}
-	return table, nil
+	return errors.WithStack(fn(client.Tables().Get(name)))
 }
 
 func retry(l *slog.Logger, retryMax int, retryBackoffMs int64, f func() error) error {
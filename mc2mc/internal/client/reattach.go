@@ -0,0 +1,95 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/goto/transformers/mc2mc/internal/checkpoint"
+)
+
+// RunningInstance is an instance of a run which is still running
+type RunningInstance struct {
+	ID        string
+	Sequence  int
+	QueryHash string
+}
+
+// maxRunningInstanceLookup caps the number of running instances whose source is fetched
+// to find the instances of a run, since it's a request per instance
+const maxRunningInstanceLookup = 100
+
+// instanceSummary is an instance returned by the instance listing
+type instanceSummary struct {
+	ID        string
+	StartTime time.Time
+}
+
+// instanceLister lists the running instances and fetches their source
+type instanceLister interface {
+	listRunningInstances(since time.Time) ([]instanceSummary, error)
+	getInstanceSource(instanceID string) (map[string]string, string, error)
+}
+
+// findRunningInstances returns the running instances started since the given time which are tagged
+// with the given run key. The listing doesn't include the hints, so the source of the newest instances
+// is fetched one by one upto maxRunningInstanceLookup.
+func findRunningInstances(l *slog.Logger, lister instanceLister, runKey string, since time.Time) ([]RunningInstance, error) {
+	summaries, err := lister.listRunningInstances(since)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	slices.SortStableFunc(summaries, func(a, b instanceSummary) int {
+		return b.StartTime.Compare(a.StartTime)
+	})
+	if len(summaries) > maxRunningInstanceLookup {
+		l.Warn(fmt.Sprintf("found %d running instances since %s, only the newest %d are looked up", len(summaries), since.Format(time.RFC3339), maxRunningInstanceLookup))
+		summaries = summaries[:maxRunningInstanceLookup]
+	}
+
+	instances := []RunningInstance{}
+	for _, summary := range summaries {
+		hints, query, err := lister.getInstanceSource(summary.ID)
+		if err != nil {
+			l.Warn(fmt.Sprintf("failed to get source of instance %s: %s", summary.ID, err))
+			continue
+		}
+		if hints[SqlRunKeyHint] != runKey {
+			continue
+		}
+		sequence, err := strconv.Atoi(hints[SqlScriptSequenceHint])
+		if err != nil {
+			l.Warn(fmt.Sprintf("invalid sequence hint of instance %s: %s", summary.ID, hints[SqlScriptSequenceHint]))
+			continue
+		}
+		instances = append(instances, RunningInstance{ID: summary.ID, Sequence: sequence, QueryHash: checkpoint.QueryHash(query)})
+	}
+	return instances, nil
+}
+
+// reattachRunning returns true if the statement is done by reattaching to the still running instance
+// of the previous attempt with the same sequence and query, so the statement isn't written twice.
+func (c *Client) reattachRunning(ctx context.Context, id int, queryHash string) (bool, error) {
+	for _, instance := range c.runningInstances {
+		if instance.Sequence != id || instance.QueryHash != queryHash {
+			continue
+		}
+		c.logger.Info(fmt.Sprintf("[sequence: %d] reattaching to running instance %s of the previous attempt", id, instance.ID))
+		if err := c.OdpsClient.Reattach(ctx, instance.ID); err != nil {
+			if errors.Is(err, ErrTerminated) {
+				return false, errors.WithStack(err)
+			}
+			c.logger.Warn(fmt.Sprintf("[sequence: %d] running instance %s of the previous attempt is not succeeded, resubmitting: %s", id, instance.ID, err))
+			return false, nil
+		}
+		c.saveCheckpoint(ctx, id, queryHash, instance.ID, checkpoint.StatusSucceeded)
+		c.logger.Info(fmt.Sprintf("[sequence: %d] execution done", id))
+		return true, nil
+	}
+	return false, nil
+}
@@ -0,0 +1,185 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/goto/transformers/mc2mc/internal/checkpoint"
+	"github.com/goto/transformers/mc2mc/internal/logger"
+)
+
+type source struct {
+	hints map[string]string
+	query string
+	err   error
+}
+
+type mockInstanceLister struct {
+	summaries []instanceSummary
+	sources   map[string]source
+	lookedUp  []string
+}
+
+func (m *mockInstanceLister) listRunningInstances(since time.Time) ([]instanceSummary, error) {
+	return m.summaries, nil
+}
+
+func (m *mockInstanceLister) getInstanceSource(instanceID string) (map[string]string, string, error) {
+	m.lookedUp = append(m.lookedUp, instanceID)
+	s := m.sources[instanceID]
+	return s.hints, s.query, s.err
+}
+
+type mockOdpsClient struct {
+	OdpsClient // methods which are not used by the tests panic
+
+	instances  []RunningInstance
+	since      time.Time
+	reattachFn func(instanceID string) error
+	reattached []string
+	executed   []string
+	terminated []string
+}
+
+func (m *mockOdpsClient) RunningInstances(ctx context.Context, runKey string, since time.Time) ([]RunningInstance, error) {
+	m.since = since
+	return m.instances, nil
+}
+
+func (m *mockOdpsClient) Reattach(ctx context.Context, instanceID string) error {
+	m.reattached = append(m.reattached, instanceID)
+	return m.reattachFn(instanceID)
+}
+
+func (m *mockOdpsClient) ExecSQL(ctx context.Context, query string, hints map[string]string) error {
+	m.executed = append(m.executed, query)
+	return nil
+}
+
+func (m *mockOdpsClient) Terminate(instanceID string) error {
+	m.terminated = append(m.terminated, instanceID)
+	return nil
+}
+
+func TestFindRunningInstances(t *testing.T) {
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	t.Run("returns instances tagged with the run key", func(t *testing.T) {
+		lister := &mockInstanceLister{
+			summaries: []instanceSummary{{ID: "i1", StartTime: now}, {ID: "i2", StartTime: now}, {ID: "i3", StartTime: now}, {ID: "i4", StartTime: now}},
+			sources: map[string]source{
+				"i1": {hints: map[string]string{SqlRunKeyHint: "key", SqlScriptSequenceHint: "2"}, query: "select 1"},
+				"i2": {hints: map[string]string{SqlRunKeyHint: "other", SqlScriptSequenceHint: "1"}, query: "select 1"},
+				"i3": {err: errors.New("not found")},
+				"i4": {hints: map[string]string{SqlRunKeyHint: "key", SqlScriptSequenceHint: "x"}, query: "select 1"},
+			},
+		}
+
+		instances, err := findRunningInstances(logger.NewDefaultLogger(), lister, "key", now)
+		assert.NoError(t, err)
+		assert.Equal(t, []RunningInstance{{ID: "i1", Sequence: 2, QueryHash: checkpoint.QueryHash("select 1")}}, instances)
+	})
+	t.Run("looks up the newest instances only upto the limit", func(t *testing.T) {
+		lister := &mockInstanceLister{}
+		for i := range maxRunningInstanceLookup + 5 {
+			lister.summaries = append(lister.summaries, instanceSummary{ID: fmt.Sprintf("i%d", i), StartTime: now.Add(time.Duration(i) * time.Minute)})
+		}
+
+		instances, err := findRunningInstances(logger.NewDefaultLogger(), lister, "key", now)
+		assert.NoError(t, err)
+		assert.Empty(t, instances)
+		assert.Len(t, lister.lookedUp, maxRunningInstanceLookup)
+		assert.Equal(t, fmt.Sprintf("i%d", maxRunningInstanceLookup+4), lister.lookedUp[0])
+		assert.NotContains(t, lister.lookedUp, "i4")
+	})
+}
+
+func TestSetupReattachPolicy(t *testing.T) {
+	newClient := func(odpsClient OdpsClient, runKey string) *Client {
+		return &Client{appCtx: context.Background(), logger: logger.NewDefaultLogger(), OdpsClient: odpsClient, runKey: runKey}
+	}
+	instances := []RunningInstance{
+		{ID: "i1", Sequence: 1, QueryHash: checkpoint.QueryHash("select 1")},
+		{ID: "i2", Sequence: 2, QueryHash: checkpoint.QueryHash("select 2")},
+	}
+
+	t.Run("doesn't look up running instances for NONE", func(t *testing.T) {
+		for _, policy := range []string{"", "none"} {
+			odpsClient := &mockOdpsClient{}
+			c := newClient(odpsClient, "")
+			assert.NoError(t, SetupReattachPolicy(policy, time.Hour)(c))
+			assert.True(t, odpsClient.since.IsZero())
+			assert.Empty(t, c.runningInstances)
+		}
+	})
+	t.Run("keeps running instances started within the lookback to reattach for WAIT", func(t *testing.T) {
+		odpsClient := &mockOdpsClient{instances: instances}
+		c := newClient(odpsClient, "key")
+		assert.NoError(t, SetupReattachPolicy("wait", 2*time.Hour)(c))
+		assert.Equal(t, instances, c.runningInstances)
+		assert.WithinDuration(t, time.Now().Add(-2*time.Hour), odpsClient.since, time.Minute)
+		assert.Empty(t, odpsClient.terminated)
+	})
+	t.Run("terminates running instances for TERMINATE", func(t *testing.T) {
+		odpsClient := &mockOdpsClient{instances: instances}
+		c := newClient(odpsClient, "key")
+		assert.NoError(t, SetupReattachPolicy("TERMINATE", time.Hour)(c))
+		assert.Equal(t, []string{"i1", "i2"}, odpsClient.terminated)
+		assert.Empty(t, c.runningInstances)
+	})
+	t.Run("returns error for unsupported policy or missing run key", func(t *testing.T) {
+		assert.ErrorContains(t, SetupReattachPolicy("KILL", time.Hour)(newClient(&mockOdpsClient{}, "key")), "not supported reattach policy: KILL")
+		assert.ErrorContains(t, SetupReattachPolicy("WAIT", time.Hour)(newClient(&mockOdpsClient{}, "")), "run key is required")
+	})
+}
+
+func TestReattachRunning(t *testing.T) {
+	newClient := func(odpsClient *mockOdpsClient) *Client {
+		return &Client{
+			appCtx:     context.Background(),
+			logger:     logger.NewDefaultLogger(),
+			OdpsClient: odpsClient,
+			runKey:     "key",
+			runningInstances: []RunningInstance{
+				{ID: "i1", Sequence: 1, QueryHash: checkpoint.QueryHash("select 1")},
+			},
+		}
+	}
+
+	t.Run("reattaches to running instance of the same sequence and query instead of executing it", func(t *testing.T) {
+		odpsClient := &mockOdpsClient{reattachFn: func(string) error { return nil }}
+		err := newClient(odpsClient).ExecuteFn(1)(context.Background(), "select 1", nil)
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"i1"}, odpsClient.reattached)
+		assert.Empty(t, odpsClient.executed)
+	})
+	t.Run("executes query when running instance is of other sequence or query", func(t *testing.T) {
+		odpsClient := &mockOdpsClient{reattachFn: func(string) error { return nil }}
+		c := newClient(odpsClient)
+		assert.NoError(t, c.ExecuteFn(2)(context.Background(), "select 1", nil))
+		assert.NoError(t, c.ExecuteFn(1)(context.Background(), "select 2", nil))
+		assert.Empty(t, odpsClient.reattached)
+		assert.Equal(t, []string{"select 1", "select 2"}, odpsClient.executed)
+	})
+	t.Run("resubmits query when running instance fails", func(t *testing.T) {
+		odpsClient := &mockOdpsClient{reattachFn: func(string) error { return errors.New("instance failed") }}
+		err := newClient(odpsClient).ExecuteFn(1)(context.Background(), "select 1", nil)
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"i1"}, odpsClient.reattached)
+		assert.Equal(t, []string{"select 1"}, odpsClient.executed)
+	})
+	t.Run("returns error without resubmitting when context is cancelled", func(t *testing.T) {
+		odpsClient := &mockOdpsClient{reattachFn: func(string) error {
+			return fmt.Errorf("%w: %w", ErrTerminated, context.Canceled)
+		}}
+		err := newClient(odpsClient).ExecuteFn(1)(context.Background(), "select 1", nil)
+		assert.ErrorIs(t, err, ErrTerminated)
+		assert.ErrorIs(t, err, context.Canceled)
+		assert.Empty(t, odpsClient.executed)
+	})
+}
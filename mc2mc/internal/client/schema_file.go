@@ -0,0 +1,140 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/pkg/errors"
+	"gopkg.in/yaml.v3"
+
+	"github.com/goto/transformers/mc2mc/pkg/query"
+)
+
+// schemaFileExtensions are the supported schema file extensions in lookup order
+var schemaFileExtensions = []string{".json", ".yaml", ".yml"}
+
+// tableSchema is the schema of a table described in local schema file
+type tableSchema struct {
+	Columns          []columnSchema `json:"columns" yaml:"columns"`
+	PartitionColumns []columnSchema `json:"partition_columns" yaml:"partition_columns"`
+}
+
+type columnSchema struct {
+	Name    string `json:"name" yaml:"name"`
+	Type    string `json:"type" yaml:"type"`
+	NotNull bool   `json:"not_null" yaml:"not_null"`
+}
+
+// fileSchemaClient provides table schemas from local files,
+// so queries can be built without access to ODPS
+type fileSchemaClient struct {
+	dir     string
+	schemas map[string]tableSchema
+}
+
+// NewFileSchemaClient creates a schema provider from local files. The path is either
+// a json or yaml file containing table schemas keyed by project.schema.table, for example:
+//
+//	{"project.schema.table": {"columns": [{"name": "id", "type": "BIGINT"}], "partition_columns": [{"name": "dt", "type": "STRING"}]}}
+//
+// or a directory containing one schema file per table named project.schema.table.(json|yaml|yml).
+// Files in a directory are only read when the table is requested.
+func NewFileSchemaClient(path string) (*fileSchemaClient, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	if info.IsDir() {
+		return &fileSchemaClient{dir: path, schemas: map[string]tableSchema{}}, nil
+	}
+
+	schemas := map[string]tableSchema{}
+	if err := unmarshalSchemaFile(path, &schemas); err != nil {
+		return nil, errors.WithStack(err)
+	}
+	return &fileSchemaClient{schemas: schemas}, nil
+}
+
+// GetPartitionNames returns the partition names of the given table
+func (c *fileSchemaClient) GetPartitionNames(_ context.Context, tableID string) ([]string, error) {
+	schema, err := c.getSchema(tableID)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	var partitionNames []string
+	for _, partition := range schema.PartitionColumns {
+		partitionNames = append(partitionNames, partition.Name)
+	}
+	return partitionNames, nil
+}
+
+// GetOrderedColumns returns the ordered column names of the given table
+func (c *fileSchemaClient) GetOrderedColumns(tableID string) ([]string, error) {
+	schema, err := c.getSchema(tableID)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	var columnNames []string
+	for _, column := range schema.Columns {
+		columnNames = append(columnNames, sanitizeColumnName(column.Name))
+	}
+	return columnNames, nil
+}
+
+// GetColumns returns the columns of the given table along with their types
+func (c *fileSchemaClient) GetColumns(tableID string) ([]query.Column, error) {
+	schema, err := c.getSchema(tableID)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	var columns []query.Column
+	for _, column := range schema.Columns {
+		columns = append(columns, query.Column{Name: sanitizeColumnName(column.Name), Type: strings.ToUpper(column.Type), NotNull: column.NotNull})
+	}
+	return columns, nil
+}
+
+func (c *fileSchemaClient) getSchema(tableID string) (tableSchema, error) {
+	if schema, ok := c.schemas[tableID]; ok {
+		return schema, nil
+	}
+	if c.dir == "" {
+		return tableSchema{}, errors.Errorf("schema of table %s is not found", tableID)
+	}
+
+	// lookup schema file of the table in the directory
+	for _, ext := range schemaFileExtensions {
+		path := filepath.Join(c.dir, tableID+ext)
+		if _, err := os.Stat(path); err != nil {
+			continue
+		}
+		var schema tableSchema
+		if err := unmarshalSchemaFile(path, &schema); err != nil {
+			return tableSchema{}, errors.WithStack(err)
+		}
+		c.schemas[tableID] = schema
+		return schema, nil
+	}
+	return tableSchema{}, errors.Errorf("schema file of table %s is not found in %s", tableID, c.dir)
+}
+
+// unmarshalSchemaFile decodes json or yaml file based on its extension
+func unmarshalSchemaFile(path string, v any) error {
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(raw, v)
+	default:
+		err = json.Unmarshal(raw, v)
+	}
+	if err != nil {
+		return errors.Wrapf(err, "invalid schema file %s", path)
+	}
+	return nil
+}
@@ -0,0 +1,70 @@
+package client_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/goto/transformers/mc2mc/internal/client"
+	"github.com/goto/transformers/mc2mc/pkg/query"
+)
+
+func TestFileSchemaClient(t *testing.T) {
+	t.Run("returns schema of the table", func(t *testing.T) {
+		testCases := []struct {
+			name    string
+			path    string
+			tableID string
+		}{
+			{name: "json file", path: "testdata/schemas.json", tableID: "project.playground.table"},
+			{name: "yaml file", path: "testdata/schemas.yaml", tableID: "project.playground.table"},
+			{name: "json file in directory", path: "testdata/schemas", tableID: "project.playground.table"},
+			{name: "yml file in directory", path: "testdata/schemas", tableID: "project.playground.table_yaml"},
+		}
+		for _, tc := range testCases {
+			t.Run(tc.name, func(t *testing.T) {
+				schemaClient, err := client.NewFileSchemaClient(tc.path)
+				assert.NoError(t, err)
+
+				columnNames, err := schemaClient.GetOrderedColumns(tc.tableID)
+				assert.NoError(t, err)
+				assert.Equal(t, []string{"id", "`date`"}, columnNames)
+
+				columns, err := schemaClient.GetColumns(tc.tableID)
+				assert.NoError(t, err)
+				assert.Equal(t, []query.Column{
+					{Name: "id", Type: "BIGINT", NotNull: true},
+					{Name: "`date`", Type: "STRING"},
+				}, columns)
+
+				partitionNames, err := schemaClient.GetPartitionNames(context.Background(), tc.tableID)
+				assert.NoError(t, err)
+				assert.Equal(t, []string{"dt"}, partitionNames)
+			})
+		}
+	})
+	t.Run("returns error for invalid schema source", func(t *testing.T) {
+		testCases := []struct {
+			name    string
+			path    string
+			tableID string
+			err     string
+		}{
+			{name: "missing path", path: "testdata/unknown.json", err: "no such file or directory"},
+			{name: "invalid file", path: "testdata/invalid.json", err: "invalid schema file testdata/invalid.json"},
+			{name: "missing table in file", path: "testdata/schemas.json", tableID: "project.playground.unknown", err: "schema of table project.playground.unknown is not found"},
+			{name: "missing table in directory", path: "testdata/schemas", tableID: "project.playground.unknown", err: "schema file of table project.playground.unknown is not found in testdata/schemas"},
+			{name: "invalid file in directory", path: "testdata/schemas", tableID: "project.playground.table_invalid", err: "invalid schema file testdata/schemas/project.playground.table_invalid.json"},
+		}
+		for _, tc := range testCases {
+			t.Run(tc.name, func(t *testing.T) {
+				schemaClient, err := client.NewFileSchemaClient(tc.path)
+				if err == nil {
+					_, err = schemaClient.GetOrderedColumns(tc.tableID)
+				}
+				assert.ErrorContains(t, err, tc.err)
+			})
+		}
+	})
+}
@@ -1,11 +1,15 @@
 package client
 
 import (
+	"fmt"
 	"log/slog"
 	"strings"
+	"time"
 
 	"github.com/aliyun/aliyun-odps-go-sdk/odps"
 	"github.com/pkg/errors"
+
+	"github.com/goto/transformers/mc2mc/internal/checkpoint"
 )
 
 type SetupFn func(c *Client) error
@@ -98,3 +102,90 @@ func SetupPriority(priority int) SetupFn {
 		return nil
 	}
 }
+
+// SetupRunKey tags every submitted instance with the given run key,
+// so the instances of the same run can be found by the next attempt
+func SetupRunKey(runKey string) SetupFn {
+	return func(c *Client) error {
+		c.runKey = runKey
+		return nil
+	}
+}
+
+// SetupCheckpoint records the status of every statement to the FILE or ODPS store under the run key,
+// statements done by the previous attempt of the run are skipped when resume is enabled
+func SetupCheckpoint(storeType, filePath, tableID string, resume bool) SetupFn {
+	return func(c *Client) error {
+		var store checkpoint.Store
+		var err error
+		switch strings.ToUpper(storeType) {
+		case "":
+			return nil
+		case "FILE":
+			store, err = checkpoint.NewFileStore(filePath)
+		case "ODPS":
+			if c.OdpsClient == nil {
+				return errors.New("odps client is required")
+			}
+			store, err = checkpoint.NewODPSStore(c.appCtx, c.OdpsClient, tableID)
+		default:
+			return errors.Errorf("not supported checkpoint store: %s", storeType)
+		}
+		if err != nil {
+			return errors.WithStack(err)
+		}
+		if c.runKey == "" {
+			return errors.New("run key is required to record checkpoints")
+		}
+		c.checkpointStore = store
+		if !resume {
+			return nil
+		}
+		checkpoints, err := store.Load(c.appCtx, c.runKey)
+		if err != nil {
+			return errors.WithStack(err)
+		}
+		c.checkpoints = checkpoints
+		c.logger.Info(fmt.Sprintf("resuming run with %d checkpoints of the previous attempt", len(checkpoints)))
+		return nil
+	}
+}
+
+// SetupReattachPolicy looks up the instances of the run started within the lookback which are still running
+// from the previous attempt, WAIT reattaches to them instead of submitting the same statements again,
+// TERMINATE terminates them
+func SetupReattachPolicy(policy string, lookback time.Duration) SetupFn {
+	return func(c *Client) error {
+		policy = strings.ToUpper(policy)
+		switch policy {
+		case "", "NONE":
+			return nil
+		case "WAIT", "TERMINATE":
+		default:
+			return errors.Errorf("not supported reattach policy: %s", policy)
+		}
+		if c.OdpsClient == nil {
+			return errors.New("odps client is required")
+		}
+		if c.runKey == "" {
+			return errors.New("run key is required to reattach running instances")
+		}
+
+		instances, err := c.OdpsClient.RunningInstances(c.appCtx, c.runKey, time.Now().Add(-lookback))
+		if err != nil {
+			return errors.WithStack(err)
+		}
+		c.logger.Info(fmt.Sprintf("found %d running instances of the previous attempt", len(instances)))
+		if policy == "WAIT" {
+			c.runningInstances = instances
+			return nil
+		}
+		for _, instance := range instances {
+			c.logger.Info(fmt.Sprintf("[sequence: %d] terminating instance %s of the previous attempt", instance.Sequence, instance.ID))
+			if err := c.OdpsClient.Terminate(instance.ID); err != nil {
+				return errors.WithStack(err)
+			}
+		}
+		return nil
+	}
+}
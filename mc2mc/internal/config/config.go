@@ -2,35 +2,88 @@ package config
 
 import (
 	"encoding/json"
+	"time"
 
 	"github.com/aliyun/aliyun-odps-go-sdk/odps"
 	"github.com/pkg/errors"
+
+	"github.com/goto/transformers/mc2mc/pkg/query"
+	"github.com/goto/transformers/mc2mc/pkg/window"
 )
 
 // ConfigEnv is a mc configuration for the component.
 type ConfigEnv struct {
-	LogLevel                    string            `env:"LOG_LEVEL" envDefault:"INFO"`
-	OtelCollectorGRPCEndpoint   string            `env:"OTEL_COLLECTOR_GRPC_ENDPOINT"`
-	OtelAttributes              string            `env:"OTEL_ATTRIBUTES"`
-	MCServiceAccount            string            `env:"MC_SERVICE_ACCOUNT"`
-	LoadMethod                  string            `env:"LOAD_METHOD" envDefault:"APPEND"`
-	QueryFilePath               string            `env:"QUERY_FILE_PATH" envDefault:"/data/in/query.sql"`
-	DestinationTableID          string            `env:"DESTINATION_TABLE_ID"`
-	CostAttributionTeam         string            `env:"COST_ATTRIBUTION_TEAM"`
-	DStart                      string            `env:"DSTART"`
-	DEnd                        string            `env:"DEND"`
-	ExecutionProject            string            `env:"EXECUTION_PROJECT"`
-	Concurrency                 int               `env:"CONCURRENCY" envDefault:"7"`
-	AdditionalHints             map[string]string `env:"ADDITIONAL_HINTS" envKeyValSeparator:"=" envSeparator:","`
-	LogViewRetentionInDays      int               `env:"LOG_VIEW_RETENTION_IN_DAYS" envDefault:"2"`
-	DisableMultiQueryGeneration bool              `env:"DISABLE_MULTI_QUERY_GENERATION" envDefault:"false"`
-	DryRun                      bool              `env:"DRY_RUN" envDefault:"false"`
-	RetryMax                    int               `env:"RETRY_MAX" envDefault:"3"`
-	RetryBackoffMs              int               `env:"RETRY_BACKOFF_MS" envDefault:"1000"`
-	Priority                    int               `env:"PRIORITY" envDefault:"9"`
+	LogLevel                        string            `env:"LOG_LEVEL" envDefault:"INFO"`
+	OtelCollectorGRPCEndpoint       string            `env:"OTEL_COLLECTOR_GRPC_ENDPOINT"`
+	OtelAttributes                  string            `env:"OTEL_ATTRIBUTES"`
+	MCServiceAccount                string            `env:"MC_SERVICE_ACCOUNT"`
+	LoadMethod                      string            `env:"LOAD_METHOD" envDefault:"APPEND"`
+	QueryFilePath                   string            `env:"QUERY_FILE_PATH" envDefault:"/data/in/query.sql"`
+	DestinationTableID              string            `env:"DESTINATION_TABLE_ID"`
+	MergeKeys                       []string          `env:"MERGE_KEYS" envSeparator:","`      // key columns for UPSERT load method
+	DeletePredicate                 string            `env:"DELETE_PREDICATE"`                 // rows to replace for DELETE_INSERT load method
+	BusinessKeys                    []string          `env:"BUSINESS_KEYS" envSeparator:","`   // key columns for SCD2 load method
+	TrackedColumns                  []string          `env:"TRACKED_COLUMNS" envSeparator:","` // all non key columns if empty
+	ValidFromColumn                 string            `env:"VALID_FROM_COLUMN" envDefault:"valid_from"`
+	ValidToColumn                   string            `env:"VALID_TO_COLUMN" envDefault:"valid_to"`
+	IsCurrentColumn                 string            `env:"IS_CURRENT_COLUMN" envDefault:"is_current"`
+	AutoCreateTable                 bool              `env:"AUTO_CREATE_TABLE" envDefault:"false"`
+	AutoCreateTablePartitionColumns []string          `env:"AUTO_CREATE_TABLE_PARTITION_COLUMNS" envSeparator:","` // name[:type], type defaults to STRING
+	AutoCreateTableLifecycle        int               `env:"AUTO_CREATE_TABLE_LIFECYCLE"`
+	AutoCreateTableComment          string            `env:"AUTO_CREATE_TABLE_COMMENT"`
+	EnableColumnMapping             bool              `env:"ENABLE_COLUMN_MAPPING" envDefault:"false"`
+	FillMissingColumns              bool              `env:"FILL_MISSING_COLUMNS" envDefault:"false"` // fill missing nullable columns with NULL
+	AllowFieldAddition              bool              `env:"ALLOW_FIELD_ADDITION" envDefault:"false"`
+	EnableTypeCasting               bool              `env:"ENABLE_TYPE_CASTING" envDefault:"false"`
+	StrictTypeCasting               bool              `env:"STRICT_TYPE_CASTING" envDefault:"false"`                   // reject lossy casts
+	PartitionSpec                   map[string]string `env:"PARTITION_SPEC" envKeyValSeparator:"=" envSeparator:","`   // static partition values for REPLACE load method
+	PartitionDateColumn             string            `env:"PARTITION_DATE_COLUMN"`                                    // partition column filled with the date of each generated query
+	PartitionValues                 map[string]string `env:"PARTITION_VALUES" envKeyValSeparator:"=" envSeparator:";"` // value per partition column, e.g. dt=date;hh=hour;region=expr:LOWER(region)
+	PartitionDateFormat             string            `env:"PARTITION_DATE_FORMAT" envDefault:"2006-01-02"`
+	CostAttributionTeam             string            `env:"COST_ATTRIBUTION_TEAM"`
+	DStart                          string            `env:"DSTART"`
+	DEnd                            string            `env:"DEND"`
+	EnableMacros                    bool              `env:"ENABLE_MACROS" envDefault:"false"`
+	ExecutionTime                   string            `env:"EXECUTION_TIME"` // RFC3339, defaults to SCHEDULED_AT, then now for EXECUTION_TIME macro
+	ScheduledAt                     string            `env:"SCHEDULED_AT"`   // RFC3339, required when window is derived
+	WindowSize                      string            `env:"WINDOW_SIZE"`    // derives DSTART and DEND when they're not set, e.g. 1d, 2h
+	WindowOffset                    string            `env:"WINDOW_OFFSET"`
+	WindowTruncateUpto              string            `env:"WINDOW_TRUNCATE_UPTO"` // h, d, w or mo
+	Timezone                        string            `env:"TIMEZONE"`             // zone of the window iteration and generated dates, offset of DSTART if empty
+	ExecutionProject                string            `env:"EXECUTION_PROJECT"`
+	Concurrency                     int               `env:"CONCURRENCY" envDefault:"7"`
+	AdditionalHints                 map[string]string `env:"ADDITIONAL_HINTS" envKeyValSeparator:"=" envSeparator:","`
+	LogViewRetentionInDays          int               `env:"LOG_VIEW_RETENTION_IN_DAYS" envDefault:"2"`
+	ReplaceGranularity              string            `env:"REPLACE_GRANULARITY" envDefault:"DAY"`          // HOUR, DAY, WEEK, MONTH or AUTO
+	BackfillChunkSize               int               `env:"BACKFILL_CHUNK_SIZE" envDefault:"0"`            // number of dates per chunk, 0 disables backfill mode
+	BackfillOrder                   string            `env:"BACKFILL_ORDER" envDefault:"OLDEST_FIRST"`      // OLDEST_FIRST or NEWEST_FIRST
+	BackfillPause                   time.Duration     `env:"BACKFILL_PAUSE" envDefault:"0s"`                // pause between chunks
+	BackfillSplitWindow             bool              `env:"BACKFILL_SPLIT_WINDOW" envDefault:"false"`      // run APPEND and MERGE once per sub-window of REPLACE_GRANULARITY
+	AtomicReplace                   bool              `env:"ATOMIC_REPLACE" envDefault:"false"`             // write REPLACE into a staging table before swapping it into destination
+	AtomicReplaceAllowEmpty         bool              `env:"ATOMIC_REPLACE_ALLOW_EMPTY" envDefault:"false"` // allow swapping staging table missing dates
+	Resume                          bool              `env:"RESUME" envDefault:"false"`                     // skip statements done by the previous attempt of the run
+	CheckpointStore                 string            `env:"CHECKPOINT_STORE"`                              // FILE or ODPS, FILE when it's empty and RESUME is enabled
+	CheckpointFilePath              string            `env:"CHECKPOINT_FILE_PATH" envDefault:"/data/out/mc2mc_checkpoint.jsonl"`
+	CheckpointTableID               string            `env:"CHECKPOINT_TABLE_ID"`
+	ReattachPolicy                  string            `env:"REATTACH_POLICY" envDefault:"NONE"`        // NONE, WAIT or TERMINATE running instances of the previous attempt
+	ReattachLookback                time.Duration     `env:"REATTACH_LOOKBACK" envDefault:"24h"`       // only instances started within the lookback are reattached
+	FailurePolicy                   string            `env:"FAILURE_POLICY" envDefault:"continue"`     // continue, fail-fast or max-failures=N of concurrent queries
+	EnableParallelMerge             bool              `env:"ENABLE_PARALLEL_MERGE" envDefault:"false"` // execute MERGE script statements writing independent tables concurrently
+	DisableMultiQueryGeneration     bool              `env:"DISABLE_MULTI_QUERY_GENERATION" envDefault:"false"`
+	DryRun                          bool              `env:"DRY_RUN" envDefault:"false"`
+	RetryMax                        int               `env:"RETRY_MAX" envDefault:"3"`
+	RetryBackoffMs                  int               `env:"RETRY_BACKOFF_MS" envDefault:"1000"`
+	Priority                        int               `env:"PRIORITY" envDefault:"9"`
+	JobName                         string            `env:"JOB_NAME"`
+	LineageNamespace                string            `env:"LINEAGE_NAMESPACE" envDefault:"mc2mc"`
+	LineageEventSink                string            `env:"LINEAGE_EVENT_SINK"` // file path or http(s) url
+	SchemaFilePath                  string            `env:"SCHEMA_FILE_PATH"`   // local table schema file or directory for offline render
 	// TODO: delete this
 	DevEnablePartitionValue string `env:"DEV__ENABLE_PARTITION_VALUE" envDefault:"false"`
 	DevEnableAutoPartition  string `env:"DEV__ENABLE_AUTO_PARTITION" envDefault:"false"`
+
+	method   query.Method
+	location *time.Location
 }
 
 type Config struct {
@@ -44,14 +97,31 @@ func NewConfig(envs ...string) (*Config, error) {
 	if err != nil {
 		return nil, errors.WithStack(err)
 	}
-	cred, err := collectMaxComputeCredential([]byte(configEnv.MCServiceAccount))
-	if err != nil {
-		return nil, errors.WithStack(err)
-	}
 	cfg := &Config{
 		Config:    &odps.Config{},
 		ConfigEnv: configEnv,
 	}
+	configEnv.method, err = query.ParseMethod(configEnv.LoadMethod)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	if configEnv.Timezone != "" {
+		configEnv.location, err = time.LoadLocation(configEnv.Timezone)
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+	}
+	if err := configEnv.deriveWindow(); err != nil {
+		return nil, errors.WithStack(err)
+	}
+	// credential is optional to allow rendering queries offline
+	if configEnv.MCServiceAccount == "" {
+		return cfg, nil
+	}
+	cred, err := collectMaxComputeCredential([]byte(configEnv.MCServiceAccount))
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
 	cfg.Config.AccessId = cred.AccessId
 	cfg.Config.AccessKey = cred.AccessKey
 	cfg.Config.Endpoint = cred.Endpoint
@@ -60,6 +130,43 @@ func NewConfig(envs ...string) (*Config, error) {
 	return cfg, nil
 }
 
+// Method returns the parsed load method, LOAD_METHOD is case-insensitive
+func (c *ConfigEnv) Method() query.Method {
+	return c.method
+}
+
+// Location returns the location of the configured timezone, nil if TIMEZONE is not set
+func (c *ConfigEnv) Location() *time.Location {
+	return c.location
+}
+
+// deriveWindow sets DSTART and DEND from the scheduled time and the window configuration
+// when they're not set, so jobs migrated from bq2bq get the same window.
+func (c *ConfigEnv) deriveWindow() error {
+	if c.WindowSize == "" || c.DStart != "" || c.DEnd != "" {
+		return nil
+	}
+	// scheduled time is required so retries derive the same window
+	if c.ScheduledAt == "" {
+		return errors.New("SCHEDULED_AT is required to derive the window from WINDOW_SIZE")
+	}
+	scheduledAt, err := time.Parse(time.RFC3339, c.ScheduledAt)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	loc := c.Location()
+	if loc == nil {
+		loc = scheduledAt.Location()
+	}
+	w, err := window.New(scheduledAt, c.WindowSize, c.WindowOffset, c.WindowTruncateUpto, loc)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	c.DStart = w.Start.Format(time.RFC3339)
+	c.DEnd = w.End.Format(time.RFC3339)
+	return nil
+}
+
 type maxComputeCredentials struct {
 	AccessId    string `json:"access_id"`
 	AccessKey   string `json:"access_key"`
@@ -0,0 +1,158 @@
+package lineage
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/pkg/errors"
+
+	"github.com/goto/transformers/mc2mc/pkg/query"
+)
+
+const (
+	producer         = "https://github.com/goto/transformers/tree/main/mc2mc"
+	schemaURL        = "https://openlineage.io/spec/2-0-2/OpenLineage.json#/definitions/RunEvent"
+	datasetNamespace = "maxcompute"
+)
+
+// EventType is the state of the run reported by the event
+type EventType string
+
+const (
+	EventStart    EventType = "START"
+	EventComplete EventType = "COMPLETE"
+	EventFail     EventType = "FAIL"
+)
+
+// Emitter reports the lineage of a mc2mc run as a structured log
+// and optionally as OpenLineage run event to a file or http endpoint
+type Emitter struct {
+	l          *slog.Logger
+	httpClient *http.Client
+
+	sink      string
+	namespace string
+	jobName   string
+	runID     string
+	lineage   query.Lineage
+}
+
+// NewEmitter creates a new lineage emitter, sink is either a file path
+// where events are appended as json lines or an http(s) url where events are posted.
+// Empty sink only reports the lineage to the log.
+func NewEmitter(l *slog.Logger, sink, namespace, jobName string, lineage query.Lineage) *Emitter {
+	return &Emitter{
+		l:          l,
+		httpClient: &http.Client{Timeout: 10 * time.Second},
+		sink:       sink,
+		namespace:  namespace,
+		jobName:    jobName,
+		runID:      uuid.NewString(),
+		lineage:    lineage,
+	}
+}
+
+// Emit reports the lineage with the given event type, failure to send
+// the event is only logged since lineage must not fail the job
+func (e *Emitter) Emit(ctx context.Context, eventType EventType) {
+	e.l.Info("lineage",
+		slog.String("event_type", string(eventType)),
+		slog.String("run_id", e.runID),
+		slog.String("job", e.jobName),
+		slog.Any("sources", e.lineage.Sources),
+		slog.Any("targets", e.lineage.Targets),
+	)
+	if e.sink == "" {
+		return
+	}
+	if err := e.send(ctx, e.newRunEvent(eventType)); err != nil {
+		e.l.Warn(fmt.Sprintf("failed to emit lineage event %s: %s", eventType, err.Error()))
+	}
+}
+
+type runEvent struct {
+	EventType EventType `json:"eventType"`
+	EventTime string    `json:"eventTime"`
+	Producer  string    `json:"producer"`
+	SchemaURL string    `json:"schemaURL"`
+	Run       run       `json:"run"`
+	Job       job       `json:"job"`
+	Inputs    []dataset `json:"inputs"`
+	Outputs   []dataset `json:"outputs"`
+}
+
+type run struct {
+	RunID string `json:"runId"`
+}
+
+type job struct {
+	Namespace string `json:"namespace"`
+	Name      string `json:"name"`
+}
+
+type dataset struct {
+	Namespace string `json:"namespace"`
+	Name      string `json:"name"`
+}
+
+func (e *Emitter) newRunEvent(eventType EventType) runEvent {
+	return runEvent{
+		EventType: eventType,
+		EventTime: time.Now().UTC().Format(time.RFC3339Nano),
+		Producer:  producer,
+		SchemaURL: schemaURL,
+		Run:       run{RunID: e.runID},
+		Job:       job{Namespace: e.namespace, Name: e.jobName},
+		Inputs:    toDatasets(e.lineage.Sources),
+		Outputs:   toDatasets(e.lineage.Targets),
+	}
+}
+
+func toDatasets(tableIDs []string) []dataset {
+	datasets := make([]dataset, len(tableIDs))
+	for i, tableID := range tableIDs {
+		datasets[i] = dataset{Namespace: datasetNamespace, Name: tableID}
+	}
+	return datasets
+}
+
+// send writes the event to the configured sink
+func (e *Emitter) send(ctx context.Context, event runEvent) error {
+	raw, err := json.Marshal(event)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+
+	if !strings.HasPrefix(e.sink, "http://") && !strings.HasPrefix(e.sink, "https://") {
+		f, err := os.OpenFile(e.sink, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o644)
+		if err != nil {
+			return errors.WithStack(err)
+		}
+		defer f.Close()
+		_, err = f.Write(append(raw, '\n'))
+		return errors.WithStack(err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, e.sink, bytes.NewReader(raw))
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := e.httpClient.Do(req)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= http.StatusMultipleChoices {
+		return errors.Errorf("unexpected status code: %d", resp.StatusCode)
+	}
+	return nil
+}
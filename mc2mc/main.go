@@ -16,9 +16,28 @@ func main() {
 
 	// Parse the flags.
 	var envs []string
+	var renderOnly bool
+	var schemaFile string
 	pflag.StringArrayVar(&envs, "env", []string{}, "pass env as argument (can be used multiple times)")
+	pflag.BoolVar(&renderOnly, "render-only", false, "print the final queries without executing them (same as render subcommand)")
+	pflag.StringVar(&schemaFile, "schema-file", "", "local table schema file or directory (json/yaml) used instead of ODPS when rendering")
 	pflag.Parse()
 
+	if schemaFile != "" {
+		envs = append(envs, fmt.Sprintf("SCHEMA_FILE_PATH=%s", schemaFile))
+	}
+
+	// render prints the final queries which would be executed by mc2mc
+	// for the given configuration without submitting anything.
+	if renderOnly || pflag.Arg(0) == "render" {
+		if err := render(envs, os.Stdout); err != nil {
+			l.Error(fmt.Sprintf("error: %s", err.Error()))
+			fmt.Printf("error: %+v\n", err)
+			os.Exit(1)
+		}
+		return
+	}
+
 	// mc2mc is the main function to execute the mc2mc transformation
 	// which reads the configuration, sets up the client and executes the query.
 	// It also handles graceful shutdown by listening to os signals.
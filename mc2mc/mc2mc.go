@@ -14,19 +14,26 @@ import (
 
 	"github.com/pkg/errors"
 
+	"github.com/goto/transformers/mc2mc/internal/checkpoint"
 	"github.com/goto/transformers/mc2mc/internal/client"
 	"github.com/goto/transformers/mc2mc/internal/config"
+	"github.com/goto/transformers/mc2mc/internal/lineage"
 	"github.com/goto/transformers/mc2mc/internal/logger"
+	"github.com/goto/transformers/mc2mc/pkg/macro"
 	"github.com/goto/transformers/mc2mc/pkg/query"
 )
 
-func mc2mc(envs []string) error {
+func mc2mc(envs []string) (err error) {
 	// load config
 	cfg, err := config.NewConfig(envs...)
 	if err != nil {
 		return errors.WithStack(err)
 	}
 
+	if cfg.MCServiceAccount == "" {
+		return errors.New("MC_SERVICE_ACCOUNT is required")
+	}
+
 	// set up logger
 	l, err := logger.NewLogger(cfg.LogLevel)
 	if err != nil {
@@ -37,6 +44,38 @@ func mc2mc(envs []string) error {
 	ctx, cancelFn := signalAwareContext(context.Background(), os.Interrupt, syscall.SIGTERM)
 	defer cancelFn()
 
+	policy, err := parseFailurePolicy(cfg.FailurePolicy)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+
+	// running instances of the previous attempt are left as is on dry run
+	reattachPolicy := cfg.ReattachPolicy
+	if cfg.DryRun {
+		reattachPolicy = "NONE"
+	}
+
+	// the run key identifies the run of the job across its attempts,
+	// it's only required to record checkpoints or reattach running instances
+	runKey := ""
+	if checkpointStore(cfg) != "" || (reattachPolicy != "" && !strings.EqualFold(reattachPolicy, "NONE")) {
+		runKey, err = checkpoint.RunKey(cfg.JobName, cfg.DStart, cfg.DEnd)
+		if err != nil {
+			return errors.WithStack(err)
+		}
+	}
+
+	// build query
+	raw, err := os.ReadFile(cfg.QueryFilePath)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+
+	// execution time must be the same on every attempt so the statements are resumed
+	if runKey != "" && cfg.EnableMacros && cfg.ExecutionTime == "" && cfg.ScheduledAt == "" && macro.References(string(raw), macro.EXECUTION_TIME) {
+		return errors.New("EXECUTION_TIME or SCHEDULED_AT is required to resume the query referencing EXECUTION_TIME macro")
+	}
+
 	// initiate client
 	c, err := client.NewClient(
 		ctx,
@@ -48,145 +87,485 @@ func mc2mc(envs []string) error {
 		client.SetupDryRun(cfg.DryRun),
 		client.SetupRetry(cfg.RetryMax, cfg.RetryBackoffMs),
 		client.SetupPriority(cfg.Priority),
+		client.SetupRunKey(runKey),
+		client.SetupCheckpoint(checkpointStore(cfg), cfg.CheckpointFilePath, cfg.CheckpointTableID, cfg.Resume),
+		client.SetupReattachPolicy(reattachPolicy, cfg.ReattachLookback),
 	)
 	if err != nil {
 		return errors.WithStack(err)
 	}
 	defer c.Close()
 
-	// parse date range
-	start, err := time.Parse(time.RFC3339, cfg.DStart)
+	// macros are rendered for the first window to extract lineage and infer the schema,
+	// the final queries render their own
+	renderedQuery, err := renderFirstWindow(cfg, string(raw))
 	if err != nil {
 		return errors.WithStack(err)
 	}
-	end, err := time.Parse(time.RFC3339, cfg.DEnd)
+
+	// report lineage of the job at start and completion, nothing is written on dry run
+	method := cfg.Method()
+	if cfg.DryRun {
+		l.Info("[DRY-RUN] lineage is not emitted")
+	} else {
+		lineageEmitter := lineage.NewEmitter(l, cfg.LineageEventSink, cfg.LineageNamespace, cfg.JobName,
+			query.ExtractLineage(renderedQuery, method, cfg.DestinationTableID))
+		lineageEmitter.Emit(ctx, lineage.EventStart)
+		defer func() {
+			eventType := lineage.EventComplete
+			if err != nil {
+				eventType = lineage.EventFail
+			}
+			lineageEmitter.Emit(context.WithoutCancel(ctx), eventType)
+		}()
+	}
+
+	odpsClient := client.NewODPSClient(l, cfg.GenOdps())
+
+	// create destination table from the query result schema if enabled
+	if cfg.AutoCreateTable && method != query.MERGE {
+		exists, err := createDestinationTable(ctx, l, cfg, c, odpsClient, renderedQuery)
+		if err != nil {
+			return errors.WithStack(err)
+		}
+		// queries can't be explained against a destination which is not created on dry run
+		if !exists {
+			l.Info(fmt.Sprintf("[DRY-RUN] queries are not explained since destination table %s doesn't exist", cfg.DestinationTableID))
+			return nil
+		}
+	}
+
+	// infer query result columns to add the new ones to destination table
+	// or to verify the type casting if enabled, inference creates a table so it's skipped on dry run
+	var queryColumns []query.Column
+	if (cfg.AllowFieldAddition || cfg.StrictTypeCasting) && (method == query.APPEND || method == query.REPLACE) {
+		if cfg.DryRun {
+			l.Info("[DRY-RUN] query columns are not inferred, no column is planned to be added")
+		} else {
+			queryColumns, err = inferQueryColumns(ctx, cfg, c, odpsClient, renderedQuery)
+			if err != nil {
+				return errors.WithStack(err)
+			}
+		}
+	}
+
+	// atomic replace writes into a staging table before replacing the destination
+	if cfg.AtomicReplace && method == query.REPLACE {
+		if !cfg.DryRun {
+			return executeAtomicReplace(ctx, l, cfg, c, policy, odpsClient, runKey, string(raw), queryColumns)
+		}
+		l.Info("[DRY-RUN] atomic replace is skipped, queries are explained against the destination")
+	}
+
+	generatedQueries, err := generateJobQueries(l, cfg, query.NewCachedClient(odpsClient), string(raw), queryColumns)
 	if err != nil {
 		return errors.WithStack(err)
 	}
 
-	// build query
-	raw, err := os.ReadFile(cfg.QueryFilePath)
+	// backfill executes the generated queries in chunks of dates
+	if cfg.BackfillChunkSize > 0 {
+		return executeBackfill(ctx, l, c, cfg, policy, 0, generatedQueries)
+	}
+
+	queriesToExecute := make([]string, len(generatedQueries))
+	for i, generatedQuery := range generatedQueries {
+		queriesToExecute[i] = generatedQuery.query
+	}
+
+	// only support concurrent execution for REPLACE method
+	if method == query.REPLACE {
+		// schema changes must be done before replacing partitions concurrently
+		ddlQueries := []string{}
+		for len(queriesToExecute) > 0 && query.IsDDL(queriesToExecute[0]) {
+			ddlQueries = append(ddlQueries, queriesToExecute[0])
+			queriesToExecute = queriesToExecute[1:]
+		}
+		if len(ddlQueries) > 0 {
+			if err := execute(ctx, l, c, 0, ddlQueries, cfg.AdditionalHints); err != nil {
+				return errors.WithStack(err)
+			}
+		}
+		return executeConcurrently(ctx, l, c, cfg.Concurrency, policy, len(ddlQueries), queriesToExecute, cfg.AdditionalHints)
+	}
+	// statements of MERGE script writing independent tables are executed concurrently if enabled
+	if method == query.MERGE && cfg.EnableParallelMerge && len(queriesToExecute) > 1 {
+		return executeDAG(ctx, l, c, cfg.Concurrency, queriesToExecute, query.Dependencies(queriesToExecute), cfg.AdditionalHints)
+	}
+	// otherwise execute sequentially
+	return execute(ctx, l, c, 0, queriesToExecute, cfg.AdditionalHints)
+}
+
+// generatedQuery is a final query to execute along with the date it's generated for
+type generatedQuery struct {
+	date  string
+	query string
+}
+
+// generateQueries builds the final queries to execute based on the load method
+// without submitting anything, table schemas are fetched through the given odps client
+// queryColumns are the inferred result columns of the query if any
+func generateQueries(l *slog.Logger, cfg *config.Config, odpsClient query.OdpsClient, raw string, queryColumns []query.Column) ([]generatedQuery, error) {
+	start, end, err := parseWindow(cfg)
 	if err != nil {
-		return errors.WithStack(err)
+		return nil, errors.WithStack(err)
+	}
+
+	// render macros of the query, multi query REPLACE renders them per partition date
+	macroValues, err := newMacroValues(cfg, start, end)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	if cfg.Method() != query.REPLACE || cfg.DisableMultiQueryGeneration {
+		raw, err = renderMacros(cfg, raw, macroValues)
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
 	}
-	queriesToExecute := []string{}
-	switch cfg.LoadMethod {
-	case "APPEND":
+
+	generatedQueries := []generatedQuery{}
+	switch cfg.Method() {
+	case query.APPEND:
 		dstart := start.Format(time.DateTime) // normalize date format as temporary support
+		options, err := windowOptions(cfg, start)
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
 		queryToExecute, err := query.NewBuilder(
 			l,
-			client.NewODPSClient(l, cfg.GenOdps()),
-			query.WithQuery(string(raw)),
+			odpsClient,
+			query.WithQuery(raw),
 			query.WithMethod(query.APPEND),
 			query.WithDestination(cfg.DestinationTableID),
-			query.WithOverridedValue("_partitiontime", fmt.Sprintf("timestamp('%s')", dstart)),
-			query.WithOverridedValue("_partitiondate", fmt.Sprintf("DATE(timestamp('%s'))", dstart)),
 			query.WithAutoPartition(cfg.DevEnableAutoPartition == "true"),
 			query.WithPartitionValue(cfg.DevEnablePartitionValue == "true"),
 			query.WithCostAttributionLabel(cfg.CostAttributionTeam),
 			query.WithColumnOrder(),
+			query.WithQueryColumns(queryColumns...),
+			query.WithFieldAddition(cfg.AllowFieldAddition),
+			query.WithTypeCasting(cfg.EnableTypeCasting, cfg.StrictTypeCasting),
+			query.WithColumnMapping(cfg.EnableColumnMapping, cfg.FillMissingColumns),
 			query.WithDryRun(cfg.DryRun),
-		).Build()
+		).SetOptions(options...).Build()
 		if err != nil {
-			return errors.WithStack(err)
+			return nil, errors.WithStack(err)
+		}
+		// schema change is executed before the query
+		for _, q := range strings.Split(queryToExecute, query.BREAK_MARKER) {
+			generatedQueries = append(generatedQueries, generatedQuery{date: dstart, query: q})
 		}
-		queriesToExecute = append(queriesToExecute, queryToExecute)
-	case "REPLACE":
+	case query.REPLACE:
 		dstart := start.Format(time.DateTime) // normalize date format as temporary support
 		queryBuilder := query.NewBuilder(
 			l,
-			client.NewODPSClient(l, cfg.GenOdps()),
+			odpsClient,
 			query.WithMethod(query.REPLACE),
 			query.WithDestination(cfg.DestinationTableID),
 			query.WithAutoPartition(cfg.DevEnableAutoPartition == "true"),
 			query.WithPartitionValue(cfg.DevEnablePartitionValue == "true"),
 			query.WithCostAttributionLabel(cfg.CostAttributionTeam),
 			query.WithColumnOrder(),
+			query.WithQueryColumns(queryColumns...),
+			query.WithFieldAddition(cfg.AllowFieldAddition),
+			query.WithTypeCasting(cfg.EnableTypeCasting, cfg.StrictTypeCasting),
+			query.WithColumnMapping(cfg.EnableColumnMapping, cfg.FillMissingColumns),
 			query.WithDryRun(cfg.DryRun),
 		)
 
 		// -- TODO(START): refactor this part --
 		// if multi query generation is disabled, then execute the query as is
 		if cfg.DisableMultiQueryGeneration {
-			queryToExecute, err := queryBuilder.SetOptions(
-				query.WithQuery(string(raw)),
-				query.WithOverridedValue("_partitiontime", fmt.Sprintf("timestamp('%s')", dstart)),
-				query.WithOverridedValue("_partitiondate", fmt.Sprintf("DATE(timestamp('%s'))", dstart)),
+			options, err := windowOptions(cfg, start)
+			if err != nil {
+				return nil, errors.WithStack(err)
+			}
+			queryToExecute, err := queryBuilder.SetOptions(options...).SetOptions(
+				query.WithQuery(raw),
 			).Build()
 			if err != nil {
-				return errors.WithStack(err)
+				return nil, errors.WithStack(err)
+			}
+			for _, q := range strings.Split(queryToExecute, query.BREAK_MARKER) {
+				generatedQueries = append(generatedQueries, generatedQuery{date: dstart, query: q})
 			}
-			queriesToExecute = append(queriesToExecute, queryToExecute)
 			break
 		}
 
-		// generate queries for each date
-		// if it contains break marker, it must uses window range greater than 1 day
+		// generate queries for each window of the configured granularity (hour, day, week or month)
+		// if it contains break marker, it must uses window range greater than a window
 		// if table destination is partition table, then it will be replaced based on the partition date
 		// for non partition table, only last query will be applied
-		queries := strings.Split(string(raw), query.BREAK_MARKER)
-		dates := []string{}
-		if end.Sub(start) <= time.Hour*24 { // if window size is less than or equal to partition delta(a DAY), then uses the same date
-			dates = append(dates, start.Format(time.DateTime)) // normalize date format as temporary support
-		} else { // otherwise, generate dates
-			for i := start; i.Before(end); i = i.AddDate(0, 0, 1) {
-				dates = append(dates, i.Format(time.DateTime)) // normalize date format as temporary support
+		granularity, err := resolveGranularity(l, cfg, odpsClient)
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+		queries := strings.Split(raw, query.BREAK_MARKER)
+		windows := query.GenerateWindows(start, end, granularity)
+		dates := make([]string, len(windows))
+		for i, window := range windows {
+			dates[i] = window.Format(time.DateTime) // normalize date format as temporary support
+		}
+
+		// a single query referencing the partition date is expanded once per date,
+		// its macros are rendered with the partition date of each copy
+		if cfg.EnableMacros && len(queries) == 1 && len(dates) > 1 && macro.References(queries[0], macro.PARTITION_DATE) {
+			l.Info(fmt.Sprintf("expanding templated query for %d dates", len(dates)))
+			for len(queries) < len(dates) {
+				queries = append(queries, queries[0])
 			}
 		}
 
 		if len(queries) != len(dates) {
-			return errors.Errorf("number of generated queries and dates are not matched: %d != %d", len(queries), len(dates))
+			return nil, errors.Errorf("number of generated queries and dates are not matched: %d != %d (granularity: %s)", len(queries), len(dates), granularity)
 		}
 
 		for i, currentQueryToExecute := range queries {
+			options, err := windowOptions(cfg, windows[i])
+			if err != nil {
+				return nil, errors.WithStack(err)
+			}
+			currentQueryToExecute, err = renderMacros(cfg, currentQueryToExecute, macroValues.With(macro.PARTITION_DATE, windows[i]))
+			if err != nil {
+				return nil, errors.WithStack(err)
+			}
 			currentQueryBuilder := queryBuilder
-			queryToExecute, err := currentQueryBuilder.SetOptions(
+			queryToExecute, err := currentQueryBuilder.SetOptions(options...).SetOptions(
 				query.WithQuery(currentQueryToExecute),
-				query.WithOverridedValue("_partitiontime", fmt.Sprintf("timestamp('%s')", dates[i])),
-				query.WithOverridedValue("_partitiondate", fmt.Sprintf("DATE(timestamp('%s'))", dates[i])),
 			).Build()
 			if err != nil {
-				return errors.WithStack(err)
+				return nil, errors.WithStack(err)
+			}
+			for _, q := range strings.Split(queryToExecute, query.BREAK_MARKER) {
+				generatedQueries = append(generatedQueries, generatedQuery{date: dates[i], query: q})
 			}
-			queriesToExecute = append(queriesToExecute, queryToExecute)
 		}
 		// -- TODO(END): refactor this part --
-	case "MERGE":
+	case query.UPSERT:
+		dstart := start.Format(time.DateTime) // normalize date format as temporary support
 		queryToExecute, err := query.NewBuilder(
 			l,
-			client.NewODPSClient(l, cfg.GenOdps()),
-			query.WithQuery(string(raw)),
+			odpsClient,
+			query.WithQuery(raw),
+			query.WithMethod(query.UPSERT),
+			query.WithDestination(cfg.DestinationTableID),
+			query.WithMergeKeys(cfg.MergeKeys...),
+			query.WithOverridedValue("_partitiontime", fmt.Sprintf("timestamp('%s')", dstart)),
+			query.WithOverridedValue("_partitiondate", fmt.Sprintf("DATE(timestamp('%s'))", dstart)),
+			query.WithCostAttributionLabel(cfg.CostAttributionTeam),
+			query.WithDryRun(cfg.DryRun),
+		).Build()
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+		generatedQueries = append(generatedQueries, generatedQuery{date: dstart, query: queryToExecute})
+	case query.DELETE_INSERT:
+		dstart := start.Format(time.DateTime) // normalize date format as temporary support
+		queryToExecute, err := query.NewBuilder(
+			l,
+			odpsClient,
+			query.WithQuery(raw),
+			query.WithMethod(query.DELETE_INSERT),
+			query.WithDestination(cfg.DestinationTableID),
+			query.WithDeletePredicate(cfg.DeletePredicate),
+			query.WithOverridedValue("_partitiontime", fmt.Sprintf("timestamp('%s')", dstart)),
+			query.WithOverridedValue("_partitiondate", fmt.Sprintf("DATE(timestamp('%s'))", dstart)),
+			query.WithAutoPartition(cfg.DevEnableAutoPartition == "true"),
+			query.WithCostAttributionLabel(cfg.CostAttributionTeam),
+			query.WithDryRun(cfg.DryRun),
+		).Build()
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+		generatedQueries = append(generatedQueries, generatedQuery{date: dstart, query: queryToExecute})
+	case query.SCD2:
+		dstart := start.Format(time.DateTime) // normalize date format as temporary support
+		queryToExecute, err := query.NewBuilder(
+			l,
+			odpsClient,
+			query.WithQuery(raw),
+			query.WithMethod(query.SCD2),
+			query.WithDestination(cfg.DestinationTableID),
+			query.WithBusinessKeys(cfg.BusinessKeys...),
+			query.WithTrackedColumns(cfg.TrackedColumns...),
+			query.WithValidityColumns(cfg.ValidFromColumn, cfg.ValidToColumn, cfg.IsCurrentColumn),
+			query.WithOverridedValue(cfg.ValidFromColumn, fmt.Sprintf("timestamp('%s')", dstart)),
+			query.WithAutoPartition(cfg.DevEnableAutoPartition == "true"),
+			query.WithCostAttributionLabel(cfg.CostAttributionTeam),
+			query.WithDryRun(cfg.DryRun),
+		).Build()
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+		// close-out and insert statements are executed in order
+		for _, q := range strings.Split(queryToExecute, query.BREAK_MARKER) {
+			generatedQueries = append(generatedQueries, generatedQuery{date: dstart, query: q})
+		}
+	case query.MERGE:
+		dstart := start.Format(time.DateTime) // normalize date format as temporary support
+		queryToExecute, err := query.NewBuilder(
+			l,
+			odpsClient,
+			query.WithQuery(raw),
 			query.WithCostAttributionLabel(cfg.CostAttributionTeam),
 			query.WithMethod(query.MERGE),
 			query.WithDryRun(cfg.DryRun),
 		).Build()
 		if err != nil {
-			return errors.WithStack(err)
+			return nil, errors.WithStack(err)
+		}
+		for _, q := range strings.Split(queryToExecute, query.BREAK_MARKER) {
+			generatedQueries = append(generatedQueries, generatedQuery{date: dstart, query: q})
 		}
-		queriesToExecute = append(queriesToExecute, strings.Split(queryToExecute, query.BREAK_MARKER)...)
 	default:
-		return errors.Errorf("not supported load method: %s", cfg.LoadMethod)
+		return nil, errors.Errorf("not supported load method: %s", cfg.LoadMethod)
 	}
 
-	// only support concurrent execution for REPLACE method
-	if cfg.LoadMethod == "REPLACE" {
-		return executeConcurrently(ctx, l, c, cfg.Concurrency, queriesToExecute, cfg.AdditionalHints)
+	return generatedQueries, nil
+}
+
+// checkpointStore returns the type of the checkpoint store,
+// checkpoints are not recorded on dry run since nothing is written
+func checkpointStore(cfg *config.Config) string {
+	if cfg.DryRun {
+		return ""
 	}
-	// otherwise execute sequentially
-	return execute(ctx, l, c, queriesToExecute, cfg.AdditionalHints)
+	if cfg.CheckpointStore == "" && cfg.Resume {
+		return "FILE"
+	}
+	return cfg.CheckpointStore
+}
+
+// resolveGranularity returns the configured granularity of the generated windows,
+// it's detected from the destination partitions when it's AUTO
+func resolveGranularity(l *slog.Logger, cfg *config.Config, odpsClient query.OdpsClient) (query.Granularity, error) {
+	granularity, err := query.ParseGranularity(cfg.ReplaceGranularity)
+	if err != nil {
+		return granularity, errors.WithStack(err)
+	}
+	if granularity != query.AUTO {
+		return granularity, nil
+	}
+	partitionNames, err := odpsClient.GetPartitionNames(context.Background(), cfg.DestinationTableID)
+	if err != nil {
+		return granularity, errors.WithStack(err)
+	}
+	granularity = query.DetectGranularity(partitionNames, cfg.PartitionValues)
+	l.Info(fmt.Sprintf("detected granularity: %s", granularity))
+	return granularity, nil
 }
 
-func executeConcurrently(ctx context.Context, l *slog.Logger, c *client.Client, concurrency int, queriesToExecute []string, additionalHints map[string]string) error {
+// parseWindow parses the date range of the job, the dates are generated in the configured
+// timezone if any, otherwise in the offset passed by the scheduler
+func parseWindow(cfg *config.Config) (time.Time, time.Time, error) {
+	start, err := time.Parse(time.RFC3339, cfg.DStart)
+	if err != nil {
+		return time.Time{}, time.Time{}, errors.WithStack(err)
+	}
+	end, err := time.Parse(time.RFC3339, cfg.DEnd)
+	if err != nil {
+		return time.Time{}, time.Time{}, errors.WithStack(err)
+	}
+	if loc := cfg.Location(); loc != nil {
+		return start.In(loc), end.In(loc), nil
+	}
+	return start, end, nil
+}
+
+// newMacroValues returns the values of the query macros available for every load method
+func newMacroValues(cfg *config.Config, start, end time.Time) (macro.Values, error) {
+	// execution time defaults to the scheduled time, then to now
+	executionTime := time.Now()
+	for _, value := range []string{cfg.ScheduledAt, cfg.ExecutionTime} {
+		if value == "" {
+			continue
+		}
+		var err error
+		executionTime, err = time.Parse(time.RFC3339, value)
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+	}
+	return macro.Values{
+		macro.DSTART:            start,
+		macro.DEND:              end,
+		macro.EXECUTION_TIME:    executionTime.In(start.Location()),
+		macro.DESTINATION_TABLE: cfg.DestinationTableID,
+	}, nil
+}
+
+// renderFirstWindow renders the macros of the query with the partition date
+// of the first window for multi query REPLACE
+func renderFirstWindow(cfg *config.Config, raw string) (string, error) {
+	start, end, err := parseWindow(cfg)
+	if err != nil {
+		return "", errors.WithStack(err)
+	}
+	values, err := newMacroValues(cfg, start, end)
+	if err != nil {
+		return "", errors.WithStack(err)
+	}
+	if cfg.Method() == query.REPLACE && !cfg.DisableMultiQueryGeneration {
+		values = values.With(macro.PARTITION_DATE, start)
+	}
+	return renderMacros(cfg, raw, values)
+}
+
+// renderMacros renders the macros of the query if they're enabled,
+// otherwise the query is returned as is
+func renderMacros(cfg *config.Config, raw string, values macro.Values) (string, error) {
+	if !cfg.EnableMacros {
+		return raw, nil
+	}
+	return macro.Render(raw, values)
+}
+
+// windowOptions returns the builder options of the values derived from the window date
+// of the generated query, such as the overrided pseudo columns and the partition values
+func windowOptions(cfg *config.Config, t time.Time) ([]query.Option, error) {
+	date := t.Format(time.DateTime) // normalize date format as temporary support
+	options := []query.Option{
+		query.WithOverridedValue("_partitiontime", fmt.Sprintf("timestamp('%s')", date)),
+		query.WithOverridedValue("_partitiondate", fmt.Sprintf("DATE(timestamp('%s'))", date)),
+	}
+	for column, value := range cfg.PartitionSpec {
+		options = append(options, query.WithStaticPartition(column, value))
+	}
+	if cfg.PartitionDateColumn != "" {
+		options = append(options, query.WithStaticPartition(cfg.PartitionDateColumn, t.Format(cfg.PartitionDateFormat)))
+	}
+	return append(options, query.PartitionValueOptions(cfg.PartitionValues, t)...), nil
+}
+
+// queryExecutor provides the function executing the query of the given sequence id
+type queryExecutor interface {
+	ExecuteFn(id int) func(context.Context, string, map[string]string) error
+}
+
+// executeConcurrently executes the queries concurrently bounded by the concurrency,
+// the remaining queries are cancelled once the failure policy is reached:
+// in-flight instances are terminated and unscheduled queries never start.
+// Sequence ids of the queries start after the offset.
+func executeConcurrently(ctx context.Context, l *slog.Logger, c queryExecutor, concurrency int, policy failurePolicy, offset int, queriesToExecute []string, additionalHints map[string]string) error {
+	execCtx, cancel := context.WithCancelCause(ctx)
+	defer cancel(nil)
+
 	// execute query concurrently
 	sem := make(chan uint8, concurrency)
 	wg := sync.WaitGroup{}
-	wg.Add(len(queriesToExecute))
 	errChan := make(chan error, len(queriesToExecute))
 	ids := sync.Map{} // id to boolean map to track running ids
+	summary := &executionSummary{}
 
 	for i, queryToExecute := range queriesToExecute {
-		sem <- 0
-		id := i + 1
+		id := offset + i + 1
+		select {
+		case sem <- 0:
+		case <-execCtx.Done():
+		}
+		if execCtx.Err() != nil { // unscheduled queries never start
+			summary.add(id, nil, true)
+			continue
+		}
+		wg.Add(1)
 		ids.Store(id, false)
 		executeFn := c.ExecuteFn(id)
 		go func(id int, queryToExecute string, errChan chan error) {
@@ -205,10 +584,18 @@ func executeConcurrently(ctx context.Context, l *slog.Logger, c *client.Client,
 					l.Info(fmt.Sprintf("waiting for %d other queries to finish...", len(remainingIds)))
 				}
 			}()
-			err := executeFn(ctx, queryToExecute, additionalHints)
+			err := executeFn(execCtx, queryToExecute, additionalHints)
+			if errors.Is(err, client.ErrTerminated) { // in-flight instance is terminated on cancellation
+				summary.add(id, nil, true)
+				return
+			}
 			if err != nil {
 				errChan <- errors.WithStack(err)
 			}
+			failures := summary.add(id, err, false)
+			if err != nil && policy.isReached(failures) {
+				cancel(errors.Errorf("cancelled after %d failed queries", failures))
+			}
 		}(id, queryToExecute, errChan)
 	}
 
@@ -216,6 +603,7 @@ func executeConcurrently(ctx context.Context, l *slog.Logger, c *client.Client,
 	close(errChan)
 
 	l.Info("all queries have been processed")
+	l.Info(fmt.Sprintf("execution summary: %s", summary))
 
 	// check error
 	var errs error
@@ -224,13 +612,20 @@ func executeConcurrently(ctx context.Context, l *slog.Logger, c *client.Client,
 			errs = e.Join(errs, err)
 		}
 	}
-	return errs
+	if errs == nil && ctx.Err() != nil {
+		errs = context.Cause(ctx)
+	}
+	if errs != nil {
+		return errors.Wrapf(errs, "execution summary: %s", summary)
+	}
+	return nil
 }
 
-func execute(ctx context.Context, l *slog.Logger, c *client.Client, queriesToExecute []string, additionalHints map[string]string) error {
+// execute executes the queries in order, sequence ids of the queries start after the offset
+func execute(ctx context.Context, l *slog.Logger, c queryExecutor, offset int, queriesToExecute []string, additionalHints map[string]string) error {
 	for i, queryToExecute := range queriesToExecute {
 		l.Info(fmt.Sprintf("processing query %d of %d", i+1, len(queriesToExecute)))
-		executeFn := c.ExecuteFn(i + 1)
+		executeFn := c.ExecuteFn(offset + i + 1)
 		err := executeFn(ctx, queryToExecute, additionalHints)
 		if err != nil {
 			return errors.WithStack(err)
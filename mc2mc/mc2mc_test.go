@@ -0,0 +1,109 @@
+package main
+
+import (
+	"context"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/goto/transformers/mc2mc/internal/config"
+	"github.com/goto/transformers/mc2mc/internal/logger"
+	"github.com/goto/transformers/mc2mc/pkg/query"
+)
+
+type mockOdpsClient struct {
+	orderedColumns []string
+	partitionNames []string
+}
+
+func (m *mockOdpsClient) GetOrderedColumns(tableID string) ([]string, error) {
+	return m.orderedColumns, nil
+}
+
+func (m *mockOdpsClient) GetPartitionNames(ctx context.Context, tableID string) ([]string, error) {
+	return m.partitionNames, nil
+}
+
+func (m *mockOdpsClient) GetColumns(tableID string) ([]query.Column, error) {
+	return nil, nil
+}
+
+// fakeExecutor records the executed queries in order and by their sequence id,
+// queries are executed with the given function if any
+type fakeExecutor struct {
+	mu        sync.Mutex
+	queries   []string
+	executed  map[int]string
+	discarded bool
+	fn        func(ctx context.Context, id int, query string) error
+}
+
+func (f *fakeExecutor) ExecuteFn(id int) func(context.Context, string, map[string]string) error {
+	return func(ctx context.Context, query string, _ map[string]string) error {
+		f.mu.Lock()
+		if f.executed == nil {
+			f.executed = map[int]string{}
+		}
+		f.queries = append(f.queries, query)
+		f.executed[id] = query
+		f.mu.Unlock()
+		if f.fn == nil {
+			return nil
+		}
+		return f.fn(ctx, id, query)
+	}
+}
+
+func (f *fakeExecutor) DiscardCheckpoints() {
+	f.discarded = true
+}
+
+func TestGenerateQueries(t *testing.T) {
+	odpsClient := &mockOdpsClient{orderedColumns: []string{"id", "name"}, partitionNames: []string{"dt"}}
+	newConfig := func(t *testing.T) *config.Config {
+		cfg, err := config.NewConfig(
+			"LOAD_METHOD=REPLACE",
+			"DESTINATION_TABLE_ID=project.playground.table_destination",
+			"DSTART=2024-01-01T00:00:00Z",
+			"DEND=2024-01-03T00:00:00Z",
+			"ENABLE_MACROS=true",
+		)
+		assert.NoError(t, err)
+		return cfg
+	}
+
+	t.Run("expands query referencing partition date once per date", func(t *testing.T) {
+		generatedQueries, err := generateQueries(logger.NewDefaultLogger(), newConfig(t), odpsClient,
+			`select id, name from project.playground.table where dt = '{{ .PARTITION_DATE | date "2006-01-02" }}'`, nil)
+		assert.NoError(t, err)
+		assert.Len(t, generatedQueries, 2)
+		assert.Equal(t, "2024-01-01 00:00:00", generatedQueries[0].date)
+		assert.Contains(t, generatedQueries[0].query, "where dt = '2024-01-01'")
+		assert.Equal(t, "2024-01-02 00:00:00", generatedQueries[1].date)
+		assert.Contains(t, generatedQueries[1].query, "where dt = '2024-01-02'")
+	})
+	t.Run("generates queries per date for case-insensitive load method", func(t *testing.T) {
+		cfg, err := config.NewConfig(
+			"LOAD_METHOD=replace",
+			"DESTINATION_TABLE_ID=project.playground.table_destination",
+			"DSTART=2024-01-01T00:00:00Z",
+			"DEND=2024-01-03T00:00:00Z",
+		)
+		assert.NoError(t, err)
+		assert.Equal(t, query.REPLACE, cfg.Method())
+
+		generatedQueries, err := generateQueries(logger.NewDefaultLogger(), cfg, odpsClient,
+			"select id, name from project.playground.table where dt = '2024-01-01';\n--*--optimus-break-marker--*--\nselect id, name from project.playground.table where dt = '2024-01-02';", nil)
+		assert.NoError(t, err)
+		assert.Len(t, generatedQueries, 2)
+		assert.Contains(t, generatedQueries[0].query, "INSERT OVERWRITE TABLE project.playground.table_destination")
+		assert.Equal(t, "2024-01-02 00:00:00", generatedQueries[1].date)
+	})
+	t.Run("doesn't expand query which only references the window", func(t *testing.T) {
+		generatedQueries, err := generateQueries(logger.NewDefaultLogger(), newConfig(t), odpsClient,
+			`select id, name from project.playground.table where dt >= '{{ .DSTART | date "2006-01-02" }}'`, nil)
+		assert.ErrorContains(t, err, "number of generated queries and dates are not matched: 1 != 2")
+		assert.Empty(t, generatedQueries)
+	})
+}
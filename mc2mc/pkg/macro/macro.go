@@ -0,0 +1,169 @@
+// Package macro renders the run-time macros of a query, e.g. {{ .DSTART | date "20060102" }}.
+package macro
+
+import (
+	"bytes"
+	"fmt"
+	"slices"
+	"strings"
+	"text/template"
+	"text/template/parse"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/goto/transformers/mc2mc/pkg/window"
+)
+
+// supported macros
+const (
+	DSTART            = "DSTART"
+	DEND              = "DEND"
+	EXECUTION_TIME    = "EXECUTION_TIME"
+	PARTITION_DATE    = "PARTITION_DATE" // only available for multi query REPLACE
+	DESTINATION_TABLE = "DESTINATION_TABLE"
+)
+
+var names = []string{DSTART, DEND, EXECUTION_TIME, PARTITION_DATE, DESTINATION_TABLE}
+
+// Values are the values of the macros available for a query
+type Values map[string]any
+
+// With returns a copy of the values with the given macro set
+func (v Values) With(name string, value any) Values {
+	values := make(Values, len(v)+1)
+	for k, val := range v {
+		values[k] = val
+	}
+	values[name] = value
+	return values
+}
+
+var funcs = template.FuncMap{
+	// date formats the time with the given go layout
+	"date": func(layout string, t time.Time) string {
+		return t.Format(layout)
+	},
+	// add shifts the time by the given duration, e.g. -1d
+	"add": func(duration string, t time.Time) (time.Time, error) {
+		d, err := window.ParseDuration(duration)
+		if err != nil {
+			return t, errors.WithStack(err)
+		}
+		return d.AddTo(t), nil
+	},
+	// truncate truncates the time upto h, d, w or mo
+	"truncate": func(upto string, t time.Time) (time.Time, error) {
+		return window.Truncate(t, upto)
+	},
+	// quote quotes the value as sql string literal
+	"quote": func(s string) string {
+		return "'" + strings.ReplaceAll(s, "'", "\\'") + "'"
+	},
+}
+
+// HasMacro returns true if the query references any macro
+func HasMacro(raw string) bool {
+	return strings.Contains(raw, "{{")
+}
+
+// References returns true if the query references the given macro,
+// query which is not a valid template references nothing
+func References(raw, name string) bool {
+	if !HasMacro(raw) {
+		return false
+	}
+	tmpl, err := template.New("query").Funcs(funcs).Parse(raw)
+	if err != nil {
+		return false
+	}
+	found := false
+	walk(tmpl.Root, func(macroName string) {
+		found = found || macroName == name
+	})
+	return found
+}
+
+// Render substitutes the macros of the query with the given values,
+// query without macros is returned as is
+func Render(raw string, values Values) (string, error) {
+	if !HasMacro(raw) {
+		return raw, nil
+	}
+	tmpl, err := template.New("query").Funcs(funcs).Option("missingkey=error").Parse(raw)
+	if err != nil {
+		return "", errors.Wrap(err, "invalid query template")
+	}
+	if err := validate(tmpl.Root, values); err != nil {
+		return "", errors.WithStack(err)
+	}
+
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, map[string]any(values)); err != nil {
+		return "", errors.Wrap(err, "failed to render query template")
+	}
+	return buf.String(), nil
+}
+
+// validate checks every macro used by the template is known and available
+func validate(node parse.Node, values Values) error {
+	var errs []string
+	seen := map[string]bool{}
+	walk(node, func(name string) {
+		if _, ok := values[name]; ok || seen[name] {
+			return
+		}
+		seen[name] = true
+		if slices.Contains(names, name) {
+			errs = append(errs, fmt.Sprintf("macro %s is not available for this query", name))
+			return
+		}
+		errs = append(errs, errors.Errorf("unknown macro %s, supported macros: %s", name, strings.Join(names, ", ")).Error())
+	})
+	if len(errs) > 0 {
+		return errors.New(strings.Join(errs, "; "))
+	}
+	return nil
+}
+
+// walk calls fn with the name of every macro referenced in the node
+func walk(node parse.Node, fn func(name string)) {
+	switch n := node.(type) {
+	case *parse.ListNode:
+		if n == nil {
+			return
+		}
+		for _, child := range n.Nodes {
+			walk(child, fn)
+		}
+	case *parse.ActionNode:
+		walk(n.Pipe, fn)
+	case *parse.IfNode:
+		walk(n.Pipe, fn)
+		walk(n.List, fn)
+		walk(n.ElseList, fn)
+	case *parse.RangeNode:
+		walk(n.Pipe, fn)
+		walk(n.List, fn)
+		walk(n.ElseList, fn)
+	case *parse.WithNode:
+		walk(n.Pipe, fn)
+		walk(n.List, fn)
+		walk(n.ElseList, fn)
+	case *parse.PipeNode:
+		if n == nil {
+			return
+		}
+		for _, cmd := range n.Cmds {
+			walk(cmd, fn)
+		}
+	case *parse.CommandNode:
+		for _, arg := range n.Args {
+			walk(arg, fn)
+		}
+	case *parse.ChainNode:
+		walk(n.Node, fn)
+	case *parse.FieldNode:
+		fn(n.Ident[0])
+	}
+}
@@ -0,0 +1,76 @@
+package macro_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/goto/transformers/mc2mc/pkg/macro"
+)
+
+func TestRender(t *testing.T) {
+	values := macro.Values{
+		macro.DSTART:            time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC),
+		macro.DEND:              time.Date(2024, 1, 2, 0, 0, 0, 0, time.UTC),
+		macro.DESTINATION_TABLE: "project.playground.table",
+	}
+
+	t.Run("returns query as is when it has no macro", func(t *testing.T) {
+		actual, err := macro.Render("select * from project.playground.table where dt = '{x}'", values)
+		assert.NoError(t, err)
+		assert.Equal(t, "select * from project.playground.table where dt = '{x}'", actual)
+	})
+	t.Run("returns query with formatted dates", func(t *testing.T) {
+		actual, err := macro.Render(`select * from {{ .DESTINATION_TABLE }} where dt >= '{{ .DSTART | date "20060102" }}' and dt < '{{ .DEND | date "20060102" }}'`, values)
+		assert.NoError(t, err)
+		assert.Equal(t, "select * from project.playground.table where dt >= '20240101' and dt < '20240102'", actual)
+	})
+	t.Run("returns query with shifted and truncated dates", func(t *testing.T) {
+		actual, err := macro.Render(`select {{ .DSTART | add "-1d" | date "2006-01-02" | quote }}, {{ .DSTART | truncate "mo" | date "2006-01-02" | quote }}`, values.With(macro.DSTART, time.Date(2024, 3, 10, 0, 0, 0, 0, time.UTC)))
+		assert.NoError(t, err)
+		assert.Equal(t, "select '2024-03-09', '2024-03-01'", actual)
+	})
+	t.Run("returns query with partition date when it's available", func(t *testing.T) {
+		actual, err := macro.Render(`select '{{ .PARTITION_DATE | date "2006-01-02" }}'`, values.With(macro.PARTITION_DATE, time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)))
+		assert.NoError(t, err)
+		assert.Equal(t, "select '2024-01-01'", actual)
+	})
+	t.Run("returns error when macro is not available", func(t *testing.T) {
+		_, err := macro.Render(`select '{{ .PARTITION_DATE | date "2006-01-02" }}'`, values)
+		assert.ErrorContains(t, err, "macro PARTITION_DATE is not available for this query")
+	})
+	t.Run("returns error when macro is unknown", func(t *testing.T) {
+		_, err := macro.Render(`select '{{ .DSTRAT | date "2006-01-02" }}'`, values)
+		assert.ErrorContains(t, err, "unknown macro DSTRAT")
+	})
+	t.Run("returns error when helper is used with wrong type", func(t *testing.T) {
+		_, err := macro.Render(`select '{{ .DESTINATION_TABLE | date "2006-01-02" }}'`, values)
+		assert.ErrorContains(t, err, "failed to render query template")
+	})
+	t.Run("returns error when template is invalid", func(t *testing.T) {
+		_, err := macro.Render(`select '{{ .DSTART | unknown }}'`, values)
+		assert.ErrorContains(t, err, "invalid query template")
+	})
+}
+
+func TestReferences(t *testing.T) {
+	t.Run("returns true when query references the macro", func(t *testing.T) {
+		assert.True(t, macro.References(`select '{{ .PARTITION_DATE | date "20060102" }}'`, macro.PARTITION_DATE))
+	})
+	t.Run("returns false when query only references other macros", func(t *testing.T) {
+		assert.False(t, macro.References(`select '{{ .DSTART | date "20060102" }}', '{{ .DEND | date "20060102" }}'`, macro.PARTITION_DATE))
+	})
+	t.Run("returns false when query is not a valid template", func(t *testing.T) {
+		assert.False(t, macro.References(`select '{{ .PARTITION_DATE'`, macro.PARTITION_DATE))
+	})
+}
+
+func TestHasMacro(t *testing.T) {
+	t.Run("returns true when query references macro", func(t *testing.T) {
+		assert.True(t, macro.HasMacro(`select '{{ .DSTART | date "20060102" }}'`))
+	})
+	t.Run("returns false when query has no macro", func(t *testing.T) {
+		assert.False(t, macro.HasMacro("select 1"))
+	})
+}
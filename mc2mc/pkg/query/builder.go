@@ -13,6 +13,7 @@ import (
 type OdpsClient interface {
 	GetOrderedColumns(tableID string) ([]string, error)
 	GetPartitionNames(ctx context.Context, tableID string) ([]string, error)
+	GetColumns(tableID string) ([]Column, error)
 }
 
 // Builder is a query builder for constructing final query
@@ -27,6 +28,22 @@ type Builder struct {
 	costAttributionTeam string
 	orderedColumns      []string
 	overridedValues     map[string]string
+	mergeKeys           []string
+	deletePredicate     string
+	businessKeys        []string
+	trackedColumns      []string
+	validFromColumn     string
+	validToColumn       string
+	isCurrentColumn     string
+	queryColumns        []Column
+	enableColumnMapping bool
+	fillMissingColumns  bool
+	enableFieldAddition bool
+	enableTypeCasting   bool
+	strictTypeCasting   bool
+	staticPartitions    map[string]string
+	// dynamic partition columns computed by expressions
+	partitionExpressions map[string]string
 
 	enableAutoPartition  bool
 	enablePartitionValue bool
@@ -66,36 +83,148 @@ func (b *Builder) Build() (string, error) {
 		return "", errors.New("query is required")
 	}
 
+	stmts := ParseStatements(b.query)
+
 	if b.method == MERGE {
 		// split query components
-		hrs, vars, queries := SplitQueryComponents(b.query)
+		hrs, vars, queries := splitStatementComponents(stmts)
 		query := b.constructMergeQuery(hrs, vars, queries)
 		return query, nil
 	}
 
-	// separate headers, variables and udfs from the query
-	hr, query := SeparateHeadersAndQuery(b.query)
-	varsAndUDFs, query := SeparateVariablesUDFsAndQuery(query)
-	drops, query := SeparateDropsAndQuery(query)
+	// separate headers, variables, udfs and drops from the query
+	headers, stmts := separateStatements(stmts, Statement.IsHeader)
+	varsAndUDFs, stmts := separateStatements(stmts, Statement.IsDeclaration)
+	dropStmts, stmts := separateStatements(stmts, func(stmt Statement) bool {
+		return stmt.Kind == StatementDrop
+	})
+	hr := joinStatements(headers, true)
+	varsAndUDFsStr := joinStatements(varsAndUDFs, true)
+	drops := make([]string, len(dropStmts))
+	for i, drop := range dropStmts {
+		drops[i] = drop.Text
+	}
+	query := joinStatements(stmts, false)
 
-	// destination table is required for append and replace method
+	// destination table is required for non merge method
 	if b.destinationTableID == "" {
 		return "", errors.New("destination table is required")
 	}
 
+	// add new query columns to destination table if enabled
+	addColumnsQuery, err := b.constructAddColumnsQuery()
+	if err != nil {
+		return "", errors.WithStack(err)
+	}
+
+	switch b.method {
+	case UPSERT:
+		query, err = b.constructUpsertQuery(query)
+	case DELETE_INSERT:
+		query, err = b.constructDeleteInsertQuery(query)
+	case SCD2:
+		query, err = b.constructSCD2Query(query)
+	default:
+		query, err = b.constructInsertQuery(query)
+	}
+	if err != nil {
+		return "", errors.WithStack(err)
+	}
+
+	// construct final query with headers, drops, variables and udfs
+	if hr != "" {
+		hr += "\n"
+	}
+	dropsStr := ""
+	if len(drops) > 0 {
+		if b.enableDryRun {
+			for i, drop := range drops {
+				drops[i] = fmt.Sprintf("EXPLAIN\n%s", drop)
+			}
+		}
+		dropsStr = strings.Join(drops, "\n;\n")
+		dropsStr += "\n;\n"
+	}
+	if varsAndUDFsStr != "" {
+		varsAndUDFsStr += "\n"
+	}
+
+	// schema change must be executed before the insert query is compiled,
+	// on dry run it's only shown since the destination is not altered
+	if addColumnsQuery != "" {
+		if b.enableDryRun {
+			hr = fmt.Sprintf("-- planned schema change:\n-- %s\n%s", strings.ReplaceAll(addColumnsQuery, "\n", "\n-- "), hr)
+		} else {
+			query = fmt.Sprintf("%s\n%s\n%s", addColumnsQuery, BREAK_MARKER, query)
+		}
+	}
+
+	// some methods generate multiple statements separated by break marker,
+	// each of them is executed separately so it needs its own headers and variables
+	parts := strings.Split(query, BREAK_MARKER)
+	for i, part := range parts {
+		part = strings.TrimSpace(part)
+		if b.enableDryRun {
+			// append explain to the query for dry run
+			part = fmt.Sprintf("EXPLAIN\n%s", part)
+		}
+		prefix := hr
+		if i == 0 {
+			prefix += dropsStr
+		}
+		if !IsDDL(part) { // skip variables if it's ddl
+			prefix += varsAndUDFsStr
+		}
+		part = prefix + part
+		if b.costAttributionTeam != "" {
+			part = fmt.Sprintf("%s\n%s\n", part, getCostAttributionComment(b.costAttributionTeam))
+		}
+		parts[i] = part
+	}
+	return strings.Join(parts, fmt.Sprintf("\n%s\n", BREAK_MARKER)), nil
+}
+
+// constructInsertQuery constructs INSERT INTO or INSERT OVERWRITE query
+// with overrided values, column order and partition of the destination table
+func (b *Builder) constructInsertQuery(query string) (string, error) {
 	var err error
 
+	// validate query columns against destination columns by name if enabled
+	if b.enableColumnMapping && b.orderedColumns != nil {
+		if err := b.constructColumnMapping(query); err != nil {
+			return "", errors.WithStack(err)
+		}
+	}
+
+	// dynamic partition columns computed by expressions are added by the first projection,
+	// the next projections keep them since they're not part of the ordered columns
+	partitionColumns, partitionExpressionNames, err := b.constructPartitionExpressions()
+	if err != nil {
+		return "", errors.WithStack(err)
+	}
+
 	// construct overrided values if enabled
 	if b.overridedValues != nil {
-		query, err = b.constructOverridedValues(query)
+		query, err = b.constructOverridedValues(query, partitionColumns)
 		if err != nil {
 			return "", errors.WithStack(err)
 		}
+		partitionColumns = partitionExpressionNames
 	}
 
 	// construct column order
 	if b.orderedColumns != nil {
-		query, err = b.constructColumnOrder(query)
+		query, err = b.constructColumnOrder(query, partitionColumns)
+		if err != nil {
+			return "", errors.WithStack(err)
+		}
+		partitionColumns = partitionExpressionNames
+	}
+
+	// partition expressions replace the query columns of the same name,
+	// so the query is projected by the destination columns if it's not yet
+	if b.overridedValues == nil && b.orderedColumns == nil && len(partitionColumns) > 0 {
+		query, err = b.constructPartitionProjection(query, partitionColumns)
 		if err != nil {
 			return "", errors.WithStack(err)
 		}
@@ -105,7 +234,7 @@ func (b *Builder) Build() (string, error) {
 	// this is for temporary solution to support partition value
 	// partition value is a pseudo column __partitionvalue,
 	// so it's not part of the ordered columns
-	if b.enablePartitionValue && !b.enableAutoPartition {
+	if b.enablePartitionValue && !b.enableAutoPartition && len(b.staticPartitions) == 0 && len(b.partitionExpressions) == 0 {
 		query, err = b.constructPartitionValue(query)
 		if err != nil {
 			return "", errors.WithStack(err)
@@ -128,55 +257,52 @@ func (b *Builder) Build() (string, error) {
 			query = fmt.Sprintf("INSERT OVERWRITE TABLE %s \n%s\n;", b.destinationTableID, query)
 		}
 	} else {
+		partitionSpec, err := b.constructPartitionSpec(partitionNames)
+		if err != nil {
+			return "", errors.WithStack(err)
+		}
 		switch b.method {
 		case APPEND:
-			query = fmt.Sprintf("INSERT INTO TABLE %s PARTITION (%s) \n%s\n;", b.destinationTableID, strings.Join(partitionNames, ", "), query)
+			query = fmt.Sprintf("INSERT INTO TABLE %s PARTITION (%s) \n%s\n;", b.destinationTableID, partitionSpec, query)
 		case REPLACE:
-			query = fmt.Sprintf("INSERT OVERWRITE TABLE %s PARTITION (%s) \n%s\n;", b.destinationTableID, strings.Join(partitionNames, ", "), query)
+			query = fmt.Sprintf("INSERT OVERWRITE TABLE %s PARTITION (%s) \n%s\n;", b.destinationTableID, partitionSpec, query)
 		}
 	}
 
-	if b.enableDryRun {
-		// append explain to the query for dry run
-		query = fmt.Sprintf("EXPLAIN\n%s", query)
-	}
-
-	// construct final query with headers, drops, variables and udfs
-	if hr != "" {
-		hr += "\n"
-	}
-	dropsStr := ""
-	if len(drops) > 0 {
-		if b.enableDryRun {
-			for i, drop := range drops {
-				drops[i] = fmt.Sprintf("EXPLAIN\n%s", drop)
-			}
-		}
-		dropsStr = strings.Join(drops, "\n;\n")
-		dropsStr += "\n;\n"
-	}
-	if varsAndUDFs != "" {
-		varsAndUDFs += "\n"
-	}
-
-	query = fmt.Sprintf("%s%s%s%s", hr, dropsStr, varsAndUDFs, query)
-	if b.costAttributionTeam != "" {
-		query = fmt.Sprintf("%s\n%s\n", query, getCostAttributionComment(b.costAttributionTeam))
-	}
 	return query, nil
 }
 
 // separateHeadersAndQuery separates headers and query from the given query
-func (b *Builder) constructColumnOrder(query string) (string, error) {
-	if b.orderedColumns == nil || len(b.orderedColumns) == 0 {
-		columns, err := b.client.GetOrderedColumns(b.destinationTableID)
+func (b *Builder) constructColumnOrder(query string, partitionColumns []string) (string, error) {
+	if err := b.fetchOrderedColumns(); err != nil {
+		return "", errors.WithStack(err)
+	}
+	columns := b.projectedColumns()
+	if b.enableTypeCasting {
+		// casting the outer projection covers the overrided values as well
+		var err error
+		columns, err = b.castColumns(columns)
 		if err != nil {
-			b.l.Error(fmt.Sprintf("failed to get ordered columns: %s", err.Error()))
 			return "", errors.WithStack(err)
 		}
-		b.orderedColumns = columns
 	}
-	return fmt.Sprintf("SELECT %s FROM (\n%s\n)", strings.Join(b.orderedColumns, ", "), query), nil
+	columns = append(columns, partitionColumns...)
+	return fmt.Sprintf("SELECT %s FROM (\n%s\n)", strings.Join(columns, ", "), query), nil
+}
+
+// fetchOrderedColumns fetches the ordered columns of the destination table
+// if they're not fetched yet
+func (b *Builder) fetchOrderedColumns() error {
+	if len(b.orderedColumns) > 0 {
+		return nil
+	}
+	columns, err := b.client.GetOrderedColumns(b.destinationTableID)
+	if err != nil {
+		b.l.Error(fmt.Sprintf("failed to get ordered columns: %s", err.Error()))
+		return errors.WithStack(err)
+	}
+	b.orderedColumns = columns
+	return nil
 }
 
 // constructPartitionValue constructs partition value for the given query
@@ -187,27 +313,24 @@ func (b *Builder) constructPartitionValue(query string) (string, error) {
 }
 
 // constructOverridedValues constructs query with overrided values
-func (b *Builder) constructOverridedValues(query string) (string, error) {
-	if b.orderedColumns == nil || len(b.orderedColumns) == 0 {
-		columns, err := b.client.GetOrderedColumns(b.destinationTableID)
-		if err != nil {
-			b.l.Error(fmt.Sprintf("failed to get ordered columns: %s", err.Error()))
-			return "", errors.WithStack(err)
-		}
-		b.orderedColumns = columns
+func (b *Builder) constructOverridedValues(query string, partitionColumns []string) (string, error) {
+	if err := b.fetchOrderedColumns(); err != nil {
+		return "", errors.WithStack(err)
 	}
-	columns := make([]string, len(b.orderedColumns))
-	for i, col := range b.orderedColumns {
+	projectedColumns := b.projectedColumns()
+	columns := make([]string, len(projectedColumns))
+	for i, col := range projectedColumns {
 		columns[i] = col
 		if val, ok := b.overridedValues[col]; ok {
 			columns[i] = fmt.Sprintf("%s as %s", val, col)
 		}
 	}
+	columns = append(columns, partitionColumns...)
 	return fmt.Sprintf("SELECT %s FROM (\n%s\n)", strings.Join(columns, ", "), query), nil
 }
 
 // constructMergeQueries constructs merge queries with headers and variables
-func (b *Builder) constructMergeQuery(hrs, vars, queries []string) string {
+func (b *Builder) constructMergeQuery(hrs, vars []string, queries []Statement) string {
 	if !b.enableDryRun && len(queries) == 1 {
 		// maintaining existing logic for single query
 		if b.costAttributionTeam != "" {
@@ -218,8 +341,7 @@ func (b *Builder) constructMergeQuery(hrs, vars, queries []string) string {
 
 	builder := strings.Builder{}
 	for i, q := range queries {
-		q = strings.TrimSpace(q)
-		if q == "" || strings.TrimSpace(RemoveComments(q)) == "" {
+		if q.Kind == StatementEmpty {
 			continue
 		}
 		headers := JoinSliceString(hrs[:i+1], "\n")
@@ -227,14 +349,14 @@ func (b *Builder) constructMergeQuery(hrs, vars, queries []string) string {
 		if headers != "" {
 			builder.WriteString(fmt.Sprintf("%s\n", headers))
 		}
-		if variables != "" && !IsDDL(q) { // skip variables if it's ddl
+		if variables != "" && !q.IsDDL() { // skip variables if it's ddl
 			builder.WriteString(fmt.Sprintf("%s\n", variables))
 		}
 		if b.enableDryRun {
 			// append explain to the DDL query part
 			builder.WriteString("EXPLAIN\n")
 		}
-		builder.WriteString(fmt.Sprintf("%s\n;", q))
+		builder.WriteString(fmt.Sprintf("%s\n;", q.Text))
 		if b.costAttributionTeam != "" {
 			builder.WriteString(fmt.Sprintf("\n%s\n", getCostAttributionComment(b.costAttributionTeam)))
 		}
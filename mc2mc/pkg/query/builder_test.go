@@ -2,6 +2,7 @@ package query_test
 
 import (
 	"context"
+	"strings"
 	"testing"
 
 	"github.com/goto/transformers/mc2mc/internal/logger"
@@ -881,6 +882,8 @@ function my_add(@a BIGINT) as @a + 1
 ;
 @src := SELECT my_add(1) id
 ;
+-- this is comment;
+
 MERGE INTO append_test
 USING (SELECT castStringToBoolean(id) FROM @src) source
 on append_test.id = source.id
@@ -1297,10 +1300,115 @@ SET append_test.id = 2
 	})
 }
 
+func TestBuilder_BuildUpsert(t *testing.T) {
+	t.Run("returns error when merge keys are not specified", func(t *testing.T) {
+		odspClient := &mockOdpsClient{}
+
+		queryToExecute, err := query.NewBuilder(
+			logger.NewDefaultLogger(),
+			odspClient,
+			query.WithQuery(`select * from project.playground.table;`),
+			query.WithMethod(query.UPSERT),
+			query.WithDestination("project.playground.table_destination"),
+		).Build()
+		assert.ErrorContains(t, err, "merge keys are required")
+		assert.Empty(t, queryToExecute)
+	})
+	t.Run("returns error when merge key is not a destination column", func(t *testing.T) {
+		odspClient := &mockOdpsClient{
+			orderedColumns: func() ([]string, error) {
+				return []string{"id", "name"}, nil
+			},
+			partitionResult: func() ([]string, error) {
+				return []string{}, nil
+			},
+		}
+
+		queryToExecute, err := query.NewBuilder(
+			logger.NewDefaultLogger(),
+			odspClient,
+			query.WithQuery(`select * from project.playground.table;`),
+			query.WithMethod(query.UPSERT),
+			query.WithDestination("project.playground.table_destination"),
+			query.WithMergeKeys("unknown"),
+		).Build()
+		assert.ErrorContains(t, err, "merge key unknown is not found")
+		assert.Empty(t, queryToExecute)
+	})
+	t.Run("returns merge into query with overrided values", func(t *testing.T) {
+		queryToExecute := `set odps.sql.allow.fullscan=true;
+select * from project.playground.table;`
+		odspClient := &mockOdpsClient{
+			orderedColumns: func() ([]string, error) {
+				return []string{"id", "name", "_partitiontime"}, nil
+			},
+			partitionResult: func() ([]string, error) {
+				return []string{}, nil
+			},
+		}
+
+		queryToExecute, err := query.NewBuilder(
+			logger.NewDefaultLogger(),
+			odspClient,
+			query.WithQuery(queryToExecute),
+			query.WithMethod(query.UPSERT),
+			query.WithDestination("project.playground.table_destination"),
+			query.WithMergeKeys("ID"),
+			query.WithOverridedValue("_partitiontime", "TIMESTAMP('2021-01-01')"),
+		).Build()
+		assert.NoError(t, err)
+		assert.Equal(t, `set odps.sql.allow.fullscan=true
+;
+MERGE INTO project.playground.table_destination AS t
+USING (
+SELECT id, name, TIMESTAMP('2021-01-01') as _partitiontime FROM (
+select * from project.playground.table
+)
+) AS s
+ON t.id = s.id
+WHEN MATCHED THEN UPDATE SET t.name = s.name, t._partitiontime = s._partitiontime
+WHEN NOT MATCHED THEN INSERT VALUES (s.id, s.name, s._partitiontime)
+;`, queryToExecute)
+	})
+	t.Run("returns merge into query for partitioned table in dry run", func(t *testing.T) {
+		odspClient := &mockOdpsClient{
+			orderedColumns: func() ([]string, error) {
+				return []string{"id", "`date`"}, nil
+			},
+			partitionResult: func() ([]string, error) {
+				return []string{"dt"}, nil
+			},
+		}
+
+		queryToExecute, err := query.NewBuilder(
+			logger.NewDefaultLogger(),
+			odspClient,
+			query.WithQuery(`select * from project.playground.table`),
+			query.WithMethod(query.UPSERT),
+			query.WithDestination("project.playground.table_destination"),
+			query.WithMergeKeys("id", "dt"),
+			query.WithDryRun(true),
+		).Build()
+		assert.NoError(t, err)
+		assert.Equal(t, `EXPLAIN
+MERGE INTO project.playground.table_destination AS t
+USING (
+SELECT id, `+"`date`"+`, dt FROM (
+select * from project.playground.table
+)
+) AS s
+ON t.id = s.id AND t.dt = s.dt
+WHEN MATCHED THEN UPDATE SET t.`+"`date`"+` = s.`+"`date`"+`
+WHEN NOT MATCHED THEN INSERT VALUES (s.id, s.`+"`date`"+`, s.dt)
+;`, queryToExecute)
+	})
+}
+
 type mockOdpsClient struct {
 	partitionResult func() ([]string, error)
 	execSQLResult   func() error
 	orderedColumns  func() ([]string, error)
+	columns         func() ([]query.Column, error)
 }
 
 func (m *mockOdpsClient) GetPartitionNames(ctx context.Context, tableID string) ([]string, error) {
@@ -1314,3 +1422,650 @@ func (m *mockOdpsClient) ExecSQL(ctx context.Context, query string) error {
 func (m *mockOdpsClient) GetOrderedColumns(tableID string) ([]string, error) {
 	return m.orderedColumns()
 }
+
+func (m *mockOdpsClient) GetColumns(tableID string) ([]query.Column, error) {
+	return m.columns()
+}
+
+func TestBuilder_BuildDeleteInsert(t *testing.T) {
+	t.Run("returns error when delete predicate is not specified", func(t *testing.T) {
+		odspClient := &mockOdpsClient{}
+
+		queryToExecute, err := query.NewBuilder(
+			logger.NewDefaultLogger(),
+			odspClient,
+			query.WithQuery(`select * from project.playground.table;`),
+			query.WithMethod(query.DELETE_INSERT),
+			query.WithDestination("project.playground.table_destination"),
+		).Build()
+		assert.ErrorContains(t, err, "delete predicate is required")
+		assert.Empty(t, queryToExecute)
+	})
+	t.Run("returns error for non partitioned table", func(t *testing.T) {
+		odspClient := &mockOdpsClient{
+			orderedColumns: func() ([]string, error) {
+				return []string{"id", "event_date"}, nil
+			},
+			partitionResult: func() ([]string, error) {
+				return []string{}, nil
+			},
+		}
+
+		queryToExecute, err := query.NewBuilder(
+			logger.NewDefaultLogger(),
+			odspClient,
+			query.WithQuery(`select * from project.playground.table`),
+			query.WithMethod(query.DELETE_INSERT),
+			query.WithDestination("project.playground.table_destination"),
+			query.WithDeletePredicate("event_date BETWEEN '2024-01-01' AND '2024-01-02'"),
+		).Build()
+		assert.ErrorContains(t, err, "delete insert method requires partitioned destination table, project.playground.table_destination is not partitioned")
+		assert.Empty(t, queryToExecute)
+	})
+	t.Run("returns overwrite query reading back only the exact target partitions of multi level partition", func(t *testing.T) {
+		odspClient := &mockOdpsClient{
+			orderedColumns: func() ([]string, error) {
+				return []string{"id", "event_date"}, nil
+			},
+			partitionResult: func() ([]string, error) {
+				return []string{"dt", "hh"}, nil
+			},
+		}
+
+		queryToExecute, err := query.NewBuilder(
+			logger.NewDefaultLogger(),
+			odspClient,
+			query.WithQuery(`select * from project.playground.table`),
+			query.WithMethod(query.DELETE_INSERT),
+			query.WithDestination("project.playground.table_destination"),
+			query.WithDeletePredicate("event_date BETWEEN '2024-01-01' AND '2024-01-02'"),
+		).Build()
+		assert.NoError(t, err)
+		assert.Equal(t, `WITH __source AS (
+SELECT id, event_date, dt, hh FROM (
+select * from project.playground.table
+)
+)
+INSERT OVERWRITE TABLE project.playground.table_destination PARTITION (dt, hh) 
+SELECT t.id, t.event_date, t.dt, t.hh FROM (
+SELECT * FROM project.playground.table_destination WHERE dt IN (SELECT DISTINCT dt FROM __source) AND hh IN (SELECT DISTINCT hh FROM __source) AND NOT COALESCE((event_date BETWEEN '2024-01-01' AND '2024-01-02'), FALSE)
+) AS t
+LEFT SEMI JOIN (SELECT DISTINCT dt, hh FROM __source) AS p ON t.dt = p.dt AND t.hh = p.hh
+UNION ALL
+SELECT id, event_date, dt, hh FROM __source
+;`, queryToExecute)
+	})
+	t.Run("returns overwrite query scoped to target partitions with headers in dry run", func(t *testing.T) {
+		odspClient := &mockOdpsClient{
+			orderedColumns: func() ([]string, error) {
+				return []string{"id", "_partitiontime"}, nil
+			},
+			partitionResult: func() ([]string, error) {
+				return []string{"dt"}, nil
+			},
+		}
+
+		queryToExecute, err := query.NewBuilder(
+			logger.NewDefaultLogger(),
+			odspClient,
+			query.WithQuery(`set odps.sql.allow.fullscan=true;
+select id, dt from project.playground.table;`),
+			query.WithMethod(query.DELETE_INSERT),
+			query.WithDestination("project.playground.table_destination"),
+			query.WithDeletePredicate("id > 10"),
+			query.WithOverridedValue("_partitiontime", "TIMESTAMP('2024-01-01')"),
+			query.WithDryRun(true),
+		).Build()
+		assert.NoError(t, err)
+		assert.Equal(t, `set odps.sql.allow.fullscan=true
+;
+EXPLAIN
+WITH __source AS (
+SELECT id, TIMESTAMP('2024-01-01') as _partitiontime, dt FROM (
+select id, dt from project.playground.table
+)
+)
+INSERT OVERWRITE TABLE project.playground.table_destination PARTITION (dt) 
+SELECT t.id, t._partitiontime, t.dt FROM (
+SELECT * FROM project.playground.table_destination WHERE dt IN (SELECT DISTINCT dt FROM __source) AND NOT COALESCE((id > 10), FALSE)
+) AS t
+UNION ALL
+SELECT id, _partitiontime, dt FROM __source
+;`, queryToExecute)
+	})
+}
+
+func TestBuilder_BuildSCD2(t *testing.T) {
+	newOdpsClient := func() *mockOdpsClient {
+		return &mockOdpsClient{
+			orderedColumns: func() ([]string, error) {
+				return []string{"id", "name", "city", "valid_from", "valid_to", "is_current"}, nil
+			},
+			partitionResult: func() ([]string, error) {
+				return []string{}, nil
+			},
+		}
+	}
+	t.Run("returns error when business keys are not specified", func(t *testing.T) {
+		queryToExecute, err := query.NewBuilder(
+			logger.NewDefaultLogger(),
+			newOdpsClient(),
+			query.WithQuery(`select * from project.playground.table`),
+			query.WithMethod(query.SCD2),
+			query.WithDestination("project.playground.table_destination"),
+			query.WithValidityColumns("valid_from", "valid_to", "is_current"),
+		).Build()
+		assert.ErrorContains(t, err, "business keys are required")
+		assert.Empty(t, queryToExecute)
+	})
+	t.Run("returns error when validity column is not found", func(t *testing.T) {
+		queryToExecute, err := query.NewBuilder(
+			logger.NewDefaultLogger(),
+			newOdpsClient(),
+			query.WithQuery(`select * from project.playground.table`),
+			query.WithMethod(query.SCD2),
+			query.WithDestination("project.playground.table_destination"),
+			query.WithBusinessKeys("id"),
+			query.WithValidityColumns("start_at", "valid_to", "is_current"),
+		).Build()
+		assert.ErrorContains(t, err, "column start_at is not found")
+		assert.Empty(t, queryToExecute)
+	})
+	t.Run("returns close-out and insert statements separated by break marker", func(t *testing.T) {
+		queryToExecute, err := query.NewBuilder(
+			logger.NewDefaultLogger(),
+			newOdpsClient(),
+			query.WithQuery(`set odps.sql.allow.fullscan=true;
+select id, name, city from project.playground.table`),
+			query.WithMethod(query.SCD2),
+			query.WithDestination("project.playground.table_destination"),
+			query.WithBusinessKeys("id"),
+			query.WithTrackedColumns("city"),
+			query.WithValidityColumns("valid_from", "valid_to", "is_current"),
+			query.WithOverridedValue("valid_from", "TIMESTAMP('2024-01-01')"),
+		).Build()
+		assert.NoError(t, err)
+		assert.Equal(t, `set odps.sql.allow.fullscan=true
+;
+MERGE INTO project.playground.table_destination AS t
+USING (
+SELECT id, name, city FROM (
+select id, name, city from project.playground.table
+)
+) AS s
+ON t.id = s.id AND t.is_current = TRUE
+WHEN MATCHED AND (NOT (t.city <=> s.city)) THEN UPDATE SET t.valid_to = TIMESTAMP('2024-01-01'), t.is_current = FALSE
+;
+`+query.BREAK_MARKER+`
+set odps.sql.allow.fullscan=true
+;
+INSERT INTO TABLE project.playground.table_destination (id, name, city, valid_from, is_current)
+SELECT s.id, s.name, s.city, TIMESTAMP('2024-01-01') as valid_from, TRUE as is_current FROM (
+SELECT id, name, city FROM (
+select id, name, city from project.playground.table
+)
+) AS s
+LEFT ANTI JOIN (SELECT * FROM project.playground.table_destination WHERE is_current = TRUE) AS t ON t.id = s.id
+;`, queryToExecute)
+	})
+	t.Run("returns explain for each statement in dry run and tracks all non key columns by default", func(t *testing.T) {
+		queryToExecute, err := query.NewBuilder(
+			logger.NewDefaultLogger(),
+			newOdpsClient(),
+			query.WithQuery(`select id, name, city from project.playground.table`),
+			query.WithMethod(query.SCD2),
+			query.WithDestination("project.playground.table_destination"),
+			query.WithBusinessKeys("id"),
+			query.WithValidityColumns("valid_from", "valid_to", "is_current"),
+			query.WithDryRun(true),
+		).Build()
+		assert.NoError(t, err)
+		parts := strings.Split(queryToExecute, query.BREAK_MARKER)
+		assert.Len(t, parts, 2)
+		assert.True(t, strings.HasPrefix(strings.TrimSpace(parts[0]), "EXPLAIN\nMERGE INTO"))
+		assert.True(t, strings.HasPrefix(strings.TrimSpace(parts[1]), "EXPLAIN\nINSERT INTO"))
+		assert.Contains(t, parts[0], "WHEN MATCHED AND (NOT (t.name <=> s.name) OR NOT (t.city <=> s.city)) THEN UPDATE SET t.valid_to = CURRENT_TIMESTAMP()")
+	})
+	t.Run("returns insert statement selecting dynamic partition columns last without listing them", func(t *testing.T) {
+		odspClient := newOdpsClient()
+		odspClient.partitionResult = func() ([]string, error) {
+			return []string{"dt"}, nil
+		}
+		queryToExecute, err := query.NewBuilder(
+			logger.NewDefaultLogger(),
+			odspClient,
+			query.WithQuery(`select id, name, city, dt from project.playground.table`),
+			query.WithMethod(query.SCD2),
+			query.WithDestination("project.playground.table_destination"),
+			query.WithBusinessKeys("id"),
+			query.WithValidityColumns("valid_from", "valid_to", "is_current"),
+		).Build()
+		assert.NoError(t, err)
+		parts := strings.Split(queryToExecute, query.BREAK_MARKER)
+		assert.Len(t, parts, 2)
+		assert.Contains(t, parts[1], `INSERT INTO TABLE project.playground.table_destination PARTITION (dt) (id, name, city, valid_from, is_current)
+SELECT s.id, s.name, s.city, CURRENT_TIMESTAMP() as valid_from, TRUE as is_current, s.dt FROM (`)
+	})
+}
+
+func TestBuilder_BuildWithFieldAddition(t *testing.T) {
+	newOdpsClient := func() *mockOdpsClient {
+		return &mockOdpsClient{
+			orderedColumns: func() ([]string, error) {
+				return []string{"id", "name"}, nil
+			},
+			partitionResult: func() ([]string, error) {
+				return []string{"dt"}, nil
+			},
+		}
+	}
+	queryColumns := []query.Column{{Name: "id", Type: "BIGINT"}, {Name: "name", Type: "STRING"}, {Name: "age", Type: "BIGINT"}, {Name: "dt", Type: "STRING"}}
+
+	t.Run("returns alter table query before the insert query for new columns", func(t *testing.T) {
+		queryToExecute, err := query.NewBuilder(
+			logger.NewDefaultLogger(),
+			newOdpsClient(),
+			query.WithQuery(`set odps.sql.allow.fullscan=true;
+@src := SELECT * FROM project.playground.table;
+select id, name, age, dt from @src;`),
+			query.WithMethod(query.APPEND),
+			query.WithDestination("project.playground.table_destination"),
+			query.WithColumnOrder(),
+			query.WithQueryColumns(queryColumns...),
+			query.WithFieldAddition(true),
+		).Build()
+		assert.NoError(t, err)
+		assert.Equal(t, `set odps.sql.allow.fullscan=true
+;
+ALTER TABLE project.playground.table_destination ADD COLUMNS IF NOT EXISTS (age BIGINT)
+;
+`+query.BREAK_MARKER+`
+set odps.sql.allow.fullscan=true
+;
+@src := SELECT * FROM project.playground.table
+;
+INSERT INTO TABLE project.playground.table_destination PARTITION (dt) 
+SELECT id, name, age FROM (
+select id, name, age, dt from @src
+)
+;`, queryToExecute)
+	})
+	t.Run("returns query as is when there's no new column", func(t *testing.T) {
+		queryToExecute, err := query.NewBuilder(
+			logger.NewDefaultLogger(),
+			newOdpsClient(),
+			query.WithQuery(`select id, name from project.playground.table`),
+			query.WithMethod(query.REPLACE),
+			query.WithDestination("project.playground.table_destination"),
+			query.WithColumnOrder(),
+			query.WithQueryColumns(queryColumns[:2]...),
+			query.WithFieldAddition(true),
+		).Build()
+		assert.NoError(t, err)
+		assert.Equal(t, `INSERT OVERWRITE TABLE project.playground.table_destination PARTITION (dt) 
+SELECT id, name FROM (
+select id, name from project.playground.table
+)
+;`, queryToExecute)
+	})
+	t.Run("returns planned alter table as comment on dry run", func(t *testing.T) {
+		queryToExecute, err := query.NewBuilder(
+			logger.NewDefaultLogger(),
+			newOdpsClient(),
+			query.WithQuery(`select id, name, age from project.playground.table`),
+			query.WithMethod(query.APPEND),
+			query.WithDestination("project.playground.table_destination"),
+			query.WithColumnOrder(),
+			query.WithQueryColumns(queryColumns...),
+			query.WithFieldAddition(true),
+			query.WithDryRun(true),
+		).Build()
+		assert.NoError(t, err)
+		assert.Equal(t, `-- planned schema change:
+-- ALTER TABLE project.playground.table_destination ADD COLUMNS IF NOT EXISTS (age BIGINT)
+-- ;
+EXPLAIN
+INSERT INTO TABLE project.playground.table_destination PARTITION (dt) 
+SELECT id, name FROM (
+select id, name, age from project.playground.table
+)
+;`, queryToExecute)
+	})
+}
+
+func TestBuilder_BuildWithColumnMapping(t *testing.T) {
+	newOdpsClient := func() *mockOdpsClient {
+		return &mockOdpsClient{
+			orderedColumns: func() ([]string, error) {
+				return []string{"id", "name", "age", "_partitiontime"}, nil
+			},
+			partitionResult: func() ([]string, error) {
+				return []string{}, nil
+			},
+			columns: func() ([]query.Column, error) {
+				return []query.Column{
+					{Name: "id", Type: "BIGINT", NotNull: true},
+					{Name: "name", Type: "STRING"},
+					{Name: "age", Type: "BIGINT"},
+					{Name: "_partitiontime", Type: "TIMESTAMP"},
+				}, nil
+			},
+		}
+	}
+
+	t.Run("returns error when query has extra columns", func(t *testing.T) {
+		queryToExecute, err := query.NewBuilder(
+			logger.NewDefaultLogger(),
+			newOdpsClient(),
+			query.WithQuery(`select id, name, age, city from project.playground.table`),
+			query.WithMethod(query.APPEND),
+			query.WithDestination("project.playground.table_destination"),
+			query.WithOverridedValue("_partitiontime", "TIMESTAMP('2024-01-01')"),
+			query.WithColumnOrder(),
+			query.WithColumnMapping(true, false),
+		).Build()
+		assert.ErrorContains(t, err, "query columns are not found in destination table project.playground.table_destination: city")
+		assert.Empty(t, queryToExecute)
+	})
+	t.Run("returns error when query misses columns", func(t *testing.T) {
+		queryToExecute, err := query.NewBuilder(
+			logger.NewDefaultLogger(),
+			newOdpsClient(),
+			query.WithQuery(`select id from project.playground.table`),
+			query.WithMethod(query.APPEND),
+			query.WithDestination("project.playground.table_destination"),
+			query.WithOverridedValue("_partitiontime", "TIMESTAMP('2024-01-01')"),
+			query.WithColumnOrder(),
+			query.WithColumnMapping(true, false),
+		).Build()
+		assert.ErrorContains(t, err, "destination columns are missing in query for table project.playground.table_destination: name, age")
+		assert.Empty(t, queryToExecute)
+	})
+	t.Run("returns error when missing column is not nullable", func(t *testing.T) {
+		queryToExecute, err := query.NewBuilder(
+			logger.NewDefaultLogger(),
+			newOdpsClient(),
+			query.WithQuery(`select name, age from project.playground.table`),
+			query.WithMethod(query.APPEND),
+			query.WithDestination("project.playground.table_destination"),
+			query.WithOverridedValue("_partitiontime", "TIMESTAMP('2024-01-01')"),
+			query.WithColumnOrder(),
+			query.WithColumnMapping(true, true),
+		).Build()
+		assert.ErrorContains(t, err, "destination column id of table project.playground.table_destination is not nullable")
+		assert.Empty(t, queryToExecute)
+	})
+	t.Run("returns query with missing nullable columns filled with null", func(t *testing.T) {
+		queryToExecute, err := query.NewBuilder(
+			logger.NewDefaultLogger(),
+			newOdpsClient(),
+			query.WithQuery(`select id, upper(name) as name from project.playground.table`),
+			query.WithMethod(query.APPEND),
+			query.WithDestination("project.playground.table_destination"),
+			query.WithOverridedValue("_partitiontime", "TIMESTAMP('2024-01-01')"),
+			query.WithColumnOrder(),
+			query.WithColumnMapping(true, true),
+		).Build()
+		assert.NoError(t, err)
+		assert.Equal(t, `INSERT INTO TABLE project.playground.table_destination 
+SELECT id, name, age, _partitiontime FROM (
+SELECT id, name, CAST(NULL AS BIGINT) as age, TIMESTAMP('2024-01-01') as _partitiontime FROM (
+select id, upper(name) as name from project.playground.table
+)
+)
+;`, queryToExecute)
+	})
+	t.Run("skips validation when query columns can't be resolved", func(t *testing.T) {
+		queryToExecute, err := query.NewBuilder(
+			logger.NewDefaultLogger(),
+			newOdpsClient(),
+			query.WithQuery(`select * from project.playground.table`),
+			query.WithMethod(query.APPEND),
+			query.WithDestination("project.playground.table_destination"),
+			query.WithColumnOrder(),
+			query.WithColumnMapping(true, true),
+		).Build()
+		assert.NoError(t, err)
+		assert.Equal(t, `INSERT INTO TABLE project.playground.table_destination 
+SELECT id, name, age, _partitiontime FROM (
+select * from project.playground.table
+)
+;`, queryToExecute)
+	})
+}
+
+func TestBuilder_BuildWithTypeCasting(t *testing.T) {
+	newOdpsClient := func() *mockOdpsClient {
+		return &mockOdpsClient{
+			orderedColumns: func() ([]string, error) {
+				return []string{"id", "amount", "_partitiontime"}, nil
+			},
+			partitionResult: func() ([]string, error) {
+				return []string{}, nil
+			},
+			columns: func() ([]query.Column, error) {
+				return []query.Column{
+					{Name: "id", Type: "STRING"},
+					{Name: "amount", Type: "DECIMAL(38,18)"},
+					{Name: "_partitiontime", Type: "TIMESTAMP"},
+				}, nil
+			},
+		}
+	}
+
+	t.Run("returns query with columns casted to destination types", func(t *testing.T) {
+		queryToExecute, err := query.NewBuilder(
+			logger.NewDefaultLogger(),
+			newOdpsClient(),
+			query.WithQuery(`select id, amount from project.playground.table`),
+			query.WithMethod(query.APPEND),
+			query.WithDestination("project.playground.table_destination"),
+			query.WithOverridedValue("_partitiontime", "timestamp('2024-01-01 00:00:00')"),
+			query.WithColumnOrder(),
+			query.WithTypeCasting(true, false),
+		).Build()
+		assert.NoError(t, err)
+		assert.Equal(t, `INSERT INTO TABLE project.playground.table_destination 
+SELECT CAST(id AS STRING) as id, CAST(amount AS DECIMAL(38,18)) as amount, CAST(_partitiontime AS TIMESTAMP) as _partitiontime FROM (
+SELECT id, amount, timestamp('2024-01-01 00:00:00') as _partitiontime FROM (
+select id, amount from project.playground.table
+)
+)
+;`, queryToExecute)
+	})
+	t.Run("returns query without casting columns with the same type", func(t *testing.T) {
+		queryToExecute, err := query.NewBuilder(
+			logger.NewDefaultLogger(),
+			newOdpsClient(),
+			query.WithQuery(`select id, amount from project.playground.table`),
+			query.WithMethod(query.APPEND),
+			query.WithDestination("project.playground.table_destination"),
+			query.WithOverridedValue("_partitiontime", "timestamp('2024-01-01 00:00:00')"),
+			query.WithColumnOrder(),
+			query.WithQueryColumns(query.Column{Name: "id", Type: "BIGINT"}, query.Column{Name: "amount", Type: "decimal(38,18)"}),
+			query.WithTypeCasting(true, true),
+		).Build()
+		assert.NoError(t, err)
+		assert.Equal(t, `INSERT INTO TABLE project.playground.table_destination 
+SELECT CAST(id AS STRING) as id, amount, CAST(_partitiontime AS TIMESTAMP) as _partitiontime FROM (
+SELECT id, amount, timestamp('2024-01-01 00:00:00') as _partitiontime FROM (
+select id, amount from project.playground.table
+)
+)
+;`, queryToExecute)
+	})
+	t.Run("returns error for lossy cast on strict mode", func(t *testing.T) {
+		queryToExecute, err := query.NewBuilder(
+			logger.NewDefaultLogger(),
+			newOdpsClient(),
+			query.WithQuery(`select id, amount from project.playground.table`),
+			query.WithMethod(query.APPEND),
+			query.WithDestination("project.playground.table_destination"),
+			query.WithColumnOrder(),
+			query.WithQueryColumns(query.Column{Name: "id", Type: "BIGINT"}, query.Column{Name: "amount", Type: "DOUBLE"}),
+			query.WithTypeCasting(true, true),
+		).Build()
+		assert.ErrorContains(t, err, "casting column amount from DOUBLE to DECIMAL(38,18) is lossy")
+		assert.Empty(t, queryToExecute)
+	})
+	t.Run("returns error for unknown query type on strict mode", func(t *testing.T) {
+		queryToExecute, err := query.NewBuilder(
+			logger.NewDefaultLogger(),
+			newOdpsClient(),
+			query.WithQuery(`select id, amount from project.playground.table`),
+			query.WithMethod(query.APPEND),
+			query.WithDestination("project.playground.table_destination"),
+			query.WithColumnOrder(),
+			query.WithTypeCasting(true, true),
+		).Build()
+		assert.ErrorContains(t, err, "type of query column id is unknown")
+		assert.Empty(t, queryToExecute)
+	})
+	t.Run("returns query casting new columns to their query type with field addition", func(t *testing.T) {
+		queryToExecute, err := query.NewBuilder(
+			logger.NewDefaultLogger(),
+			newOdpsClient(),
+			query.WithQuery(`select id, amount, age from project.playground.table`),
+			query.WithMethod(query.APPEND),
+			query.WithDestination("project.playground.table_destination"),
+			query.WithOverridedValue("_partitiontime", "timestamp('2024-01-01 00:00:00')"),
+			query.WithColumnOrder(),
+			query.WithQueryColumns(
+				query.Column{Name: "id", Type: "STRING"},
+				query.Column{Name: "amount", Type: "DECIMAL(38,18)"},
+				query.Column{Name: "age", Type: "BIGINT"},
+			),
+			query.WithFieldAddition(true),
+			query.WithTypeCasting(true, true),
+		).Build()
+		assert.NoError(t, err)
+		assert.Equal(t, `ALTER TABLE project.playground.table_destination ADD COLUMNS IF NOT EXISTS (age BIGINT)
+;
+`+query.BREAK_MARKER+`
+INSERT INTO TABLE project.playground.table_destination 
+SELECT id, amount, CAST(_partitiontime AS TIMESTAMP) as _partitiontime, age FROM (
+SELECT id, amount, timestamp('2024-01-01 00:00:00') as _partitiontime, age FROM (
+select id, amount, age from project.playground.table
+)
+)
+;`, queryToExecute)
+	})
+}
+
+func TestBuilder_BuildWithStaticPartition(t *testing.T) {
+	newOdpsClient := func() *mockOdpsClient {
+		return &mockOdpsClient{
+			orderedColumns: func() ([]string, error) {
+				return []string{"id", "name"}, nil
+			},
+			partitionResult: func() ([]string, error) {
+				return []string{"dt", "region"}, nil
+			},
+		}
+	}
+
+	t.Run("returns insert overwrite query with static partition spec", func(t *testing.T) {
+		queryToExecute, err := query.NewBuilder(
+			logger.NewDefaultLogger(),
+			newOdpsClient(),
+			query.WithQuery(`select id, name from project.playground.table`),
+			query.WithMethod(query.REPLACE),
+			query.WithDestination("project.playground.table_destination"),
+			query.WithColumnOrder(),
+			query.WithPartitionValue(true),
+			query.WithStaticPartition("region", "id"),
+			query.WithStaticPartition("DT", "2024-01-01"),
+		).Build()
+		assert.NoError(t, err)
+		assert.Equal(t, `INSERT OVERWRITE TABLE project.playground.table_destination PARTITION (dt='2024-01-01', region='id') 
+SELECT id, name FROM (
+select id, name from project.playground.table
+)
+;`, queryToExecute)
+	})
+	t.Run("returns query without static partition columns in projection", func(t *testing.T) {
+		odpsClient := newOdpsClient()
+		odpsClient.orderedColumns = func() ([]string, error) {
+			return []string{"id", "name", "dt", "region"}, nil
+		}
+		queryToExecute, err := query.NewBuilder(
+			logger.NewDefaultLogger(),
+			odpsClient,
+			query.WithQuery(`select id, name from project.playground.table`),
+			query.WithMethod(query.REPLACE),
+			query.WithDestination("project.playground.table_destination"),
+			query.WithColumnOrder(),
+			query.WithStaticPartition("dt", "2024-01-01"),
+			query.WithStaticPartition("region", "it's"),
+		).Build()
+		assert.NoError(t, err)
+		assert.Equal(t, `INSERT OVERWRITE TABLE project.playground.table_destination PARTITION (dt='2024-01-01', region='it\'s') 
+SELECT id, name FROM (
+select id, name from project.playground.table
+)
+;`, queryToExecute)
+	})
+	t.Run("returns query with static and dynamic partition mix", func(t *testing.T) {
+		queryToExecute, err := query.NewBuilder(
+			logger.NewDefaultLogger(),
+			newOdpsClient(),
+			query.WithQuery(`select id, name, region_code from project.playground.table`),
+			query.WithMethod(query.REPLACE),
+			query.WithDestination("project.playground.table_destination"),
+			query.WithColumnOrder(),
+			query.WithStaticPartition("dt", "2024-01-01"),
+			query.WithPartitionExpression("region", "LOWER(name)"),
+		).Build()
+		assert.NoError(t, err)
+		assert.Equal(t, `INSERT OVERWRITE TABLE project.playground.table_destination PARTITION (dt='2024-01-01', region) 
+SELECT id, name, LOWER(name) as region FROM (
+select id, name, region_code from project.playground.table
+)
+;`, queryToExecute)
+	})
+	t.Run("returns query with partition expression of the query column before it's projected away", func(t *testing.T) {
+		queryToExecute, err := query.NewBuilder(
+			logger.NewDefaultLogger(),
+			newOdpsClient(),
+			query.WithQuery(`select id, name, region from project.playground.table`),
+			query.WithMethod(query.REPLACE),
+			query.WithDestination("project.playground.table_destination"),
+			query.WithOverridedValue("name", "UPPER(name)"),
+			query.WithColumnOrder(),
+			query.WithStaticPartition("dt", "2024-01-01"),
+			query.WithPartitionExpression("region", "LOWER(region)"),
+		).Build()
+		assert.NoError(t, err)
+		assert.Equal(t, `INSERT OVERWRITE TABLE project.playground.table_destination PARTITION (dt='2024-01-01', region) 
+SELECT id, name, region FROM (
+SELECT id, UPPER(name) as name, LOWER(region) as region FROM (
+select id, name, region from project.playground.table
+)
+)
+;`, queryToExecute)
+	})
+	t.Run("returns error when static partition follows dynamic partition", func(t *testing.T) {
+		queryToExecute, err := query.NewBuilder(
+			logger.NewDefaultLogger(),
+			newOdpsClient(),
+			query.WithQuery(`select id, name from project.playground.table`),
+			query.WithMethod(query.REPLACE),
+			query.WithDestination("project.playground.table_destination"),
+			query.WithStaticPartition("region", "id"),
+		).Build()
+		assert.ErrorContains(t, err, "static partition column region can't follow dynamic partition column dt")
+		assert.Empty(t, queryToExecute)
+	})
+	t.Run("returns error when static partition is not a partition column", func(t *testing.T) {
+		queryToExecute, err := query.NewBuilder(
+			logger.NewDefaultLogger(),
+			newOdpsClient(),
+			query.WithQuery(`select id, name from project.playground.table`),
+			query.WithMethod(query.REPLACE),
+			query.WithDestination("project.playground.table_destination"),
+			query.WithStaticPartition("hour", "01"),
+		).Build()
+		assert.ErrorContains(t, err, "partition column hour is not found in destination table")
+		assert.Empty(t, queryToExecute)
+	})
+}
@@ -0,0 +1,75 @@
+package query
+
+import (
+	"context"
+	"sync"
+
+	"github.com/pkg/errors"
+)
+
+// cachedClient is an OdpsClient decorator which fetches
+// the schema information of each table only once
+type cachedClient struct {
+	client OdpsClient
+
+	mu             sync.Mutex
+	orderedColumns map[string][]string
+	partitionNames map[string][]string
+	columns        map[string][]Column
+}
+
+// NewCachedClient wraps the given client with in-memory cache,
+// failed lookups are not cached so they can be retried
+func NewCachedClient(client OdpsClient) OdpsClient {
+	return &cachedClient{
+		client:         client,
+		orderedColumns: make(map[string][]string),
+		partitionNames: make(map[string][]string),
+		columns:        make(map[string][]Column),
+	}
+}
+
+// GetOrderedColumns returns the cached ordered columns of the given table
+func (c *cachedClient) GetOrderedColumns(tableID string) ([]string, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if columns, ok := c.orderedColumns[tableID]; ok {
+		return append([]string(nil), columns...), nil
+	}
+	columns, err := c.client.GetOrderedColumns(tableID)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	c.orderedColumns[tableID] = columns
+	return append([]string(nil), columns...), nil
+}
+
+// GetPartitionNames returns the cached partition names of the given table
+func (c *cachedClient) GetPartitionNames(ctx context.Context, tableID string) ([]string, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if names, ok := c.partitionNames[tableID]; ok {
+		return append([]string(nil), names...), nil
+	}
+	names, err := c.client.GetPartitionNames(ctx, tableID)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	c.partitionNames[tableID] = names
+	return append([]string(nil), names...), nil
+}
+
+// GetColumns returns the cached columns of the given table
+func (c *cachedClient) GetColumns(tableID string) ([]Column, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if columns, ok := c.columns[tableID]; ok {
+		return append([]Column(nil), columns...), nil
+	}
+	columns, err := c.client.GetColumns(tableID)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	c.columns[tableID] = columns
+	return append([]Column(nil), columns...), nil
+}
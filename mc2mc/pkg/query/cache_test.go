@@ -0,0 +1,71 @@
+package query_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/goto/transformers/mc2mc/pkg/query"
+)
+
+func TestCachedClient(t *testing.T) {
+	t.Run("fetches schema of each table only once", func(t *testing.T) {
+		columnCalls, partitionCalls := 0, 0
+		odspClient := &mockOdpsClient{
+			orderedColumns: func() ([]string, error) {
+				columnCalls++
+				return []string{"col1", "col2"}, nil
+			},
+			partitionResult: func() ([]string, error) {
+				partitionCalls++
+				return []string{"dt"}, nil
+			},
+		}
+		cachedClient := query.NewCachedClient(odspClient)
+
+		for range 3 {
+			columns, err := cachedClient.GetOrderedColumns("project.playground.table")
+			assert.NoError(t, err)
+			assert.Equal(t, []string{"col1", "col2"}, columns)
+			partitions, err := cachedClient.GetPartitionNames(context.Background(), "project.playground.table")
+			assert.NoError(t, err)
+			assert.Equal(t, []string{"dt"}, partitions)
+		}
+		assert.Equal(t, 1, columnCalls)
+		assert.Equal(t, 1, partitionCalls)
+	})
+	t.Run("does not cache failed lookup", func(t *testing.T) {
+		calls := 0
+		odspClient := &mockOdpsClient{
+			orderedColumns: func() ([]string, error) {
+				calls++
+				if calls == 1 {
+					return nil, assert.AnError
+				}
+				return []string{"col1"}, nil
+			},
+		}
+		cachedClient := query.NewCachedClient(odspClient)
+
+		_, err := cachedClient.GetOrderedColumns("project.playground.table")
+		assert.Error(t, err)
+		columns, err := cachedClient.GetOrderedColumns("project.playground.table")
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"col1"}, columns)
+		assert.Equal(t, 2, calls)
+	})
+	t.Run("returns copy so callers can't modify the cache", func(t *testing.T) {
+		odspClient := &mockOdpsClient{
+			orderedColumns: func() ([]string, error) {
+				return []string{"col1"}, nil
+			},
+		}
+		cachedClient := query.NewCachedClient(odspClient)
+
+		columns, _ := cachedClient.GetOrderedColumns("project.playground.table")
+		columns[0] = "changed"
+		columns, _ = cachedClient.GetOrderedColumns("project.playground.table")
+		assert.Equal(t, []string{"col1"}, columns)
+	})
+}
@@ -0,0 +1,154 @@
+package query
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// integerDigits is the maximum number of digits of each integer type
+var integerDigits = map[string]int{
+	"TINYINT":  3,
+	"SMALLINT": 5,
+	"INT":      10,
+	"BIGINT":   19,
+}
+
+// castColumns returns the projection of the given columns casted to the destination column types.
+// Columns which already have the destination type are not casted. On strict mode, lossy casts
+// and columns with unknown query type are rejected, except for the overrided values.
+func (b *Builder) castColumns(columns []string) ([]string, error) {
+	definitions, err := b.client.GetColumns(b.destinationTableID)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	queryTypes := make(map[string]string, len(b.queryColumns))
+	for _, column := range b.queryColumns {
+		queryTypes[normalizeColumnName(column.Name)] = column.Type
+	}
+	// columns added by field addition are not in the destination yet, they have the query type
+	destinationTypes := make(map[string]string, len(definitions)+len(queryTypes))
+	for name, queryType := range queryTypes {
+		destinationTypes[name] = queryType
+	}
+	for _, definition := range definitions {
+		destinationTypes[normalizeColumnName(definition.Name)] = definition.Type
+	}
+
+	projections := make([]string, len(columns))
+	for i, column := range columns {
+		destinationType, ok := destinationTypes[normalizeColumnName(column)]
+		if !ok {
+			return nil, errors.Errorf("type of column %s is not found in destination table %s", column, b.destinationTableID)
+		}
+		projections[i] = fmt.Sprintf("CAST(%s AS %s) as %s", column, destinationType, column)
+
+		queryType, ok := queryTypes[normalizeColumnName(column)]
+		_, overrided := b.overridedValues[column]
+		switch {
+		case ok && strings.EqualFold(queryType, destinationType):
+			projections[i] = column
+		case !b.strictTypeCasting || overrided:
+		case !ok:
+			return nil, errors.Errorf("type of query column %s is unknown, it's required for strict type casting", column)
+		case IsLossyCast(queryType, destinationType):
+			return nil, errors.Errorf("casting column %s from %s to %s is lossy", column, queryType, destinationType)
+		}
+	}
+	return projections, nil
+}
+
+// IsLossyCast returns true if casting a value from one type to another
+// may lose information or fail, types are compared case insensitively
+func IsLossyCast(from, to string) bool {
+	fromName, fromParams := parseType(from)
+	toName, toParams := parseType(to)
+
+	switch {
+	case toName == "STRING":
+		return false
+	case fromName == toName:
+		switch fromName {
+		case "DECIMAL":
+			return isLossyDecimalCast(fromParams, toParams)
+		case "VARCHAR", "CHAR":
+			return len(fromParams) == 0 || len(toParams) == 0 || toParams[0] < fromParams[0]
+		}
+		// complex types are only lossless when they're identical
+		return !strings.EqualFold(strings.ReplaceAll(from, " ", ""), strings.ReplaceAll(to, " ", ""))
+	}
+
+	if fromDigits, ok := integerDigits[fromName]; ok {
+		if toDigits, ok := integerDigits[toName]; ok {
+			return toDigits < fromDigits
+		}
+		switch toName {
+		case "DECIMAL":
+			return isLossyDecimalCast([]int{fromDigits, 0}, toParams)
+		case "DOUBLE":
+			return fromName == "BIGINT" // double has 53 bits of precision
+		case "FLOAT":
+			return fromName == "INT" || fromName == "BIGINT" // float has 24 bits of precision
+		}
+		return true
+	}
+
+	switch fromName {
+	case "FLOAT":
+		return toName != "DOUBLE"
+	case "DATE":
+		return toName != "DATETIME" && toName != "TIMESTAMP" && toName != "TIMESTAMP_NTZ"
+	case "DATETIME":
+		return toName != "TIMESTAMP" && toName != "TIMESTAMP_NTZ"
+	case "VARCHAR", "CHAR":
+		return toName != "VARCHAR" || len(toParams) == 0 || len(fromParams) == 0 || toParams[0] < fromParams[0]
+	}
+	return true
+}
+
+// isLossyDecimalCast returns true if decimal with the given precision and scale
+// can't be represented by the target precision and scale
+func isLossyDecimalCast(from, to []int) bool {
+	// decimal without params is the legacy decimal with maximum precision
+	if len(to) == 0 {
+		return false
+	}
+	if len(from) == 0 {
+		return true
+	}
+	fromScale, toScale := 0, 0
+	if len(from) > 1 {
+		fromScale = from[1]
+	}
+	if len(to) > 1 {
+		toScale = to[1]
+	}
+	return toScale < fromScale || to[0]-toScale < from[0]-fromScale
+}
+
+// parseType returns the upper case name and numeric params of the given type,
+// for example DECIMAL(38,18) returns DECIMAL and [38 18]
+func parseType(t string) (string, []int) {
+	t = strings.ToUpper(strings.TrimSpace(t))
+	name, rest, ok := strings.Cut(t, "(")
+	if !ok {
+		name, _, _ = strings.Cut(t, "<")
+		return strings.TrimSpace(name), nil
+	}
+	params := []int{}
+	for _, param := range strings.Split(strings.TrimSuffix(rest, ")"), ",") {
+		value, err := strconv.Atoi(strings.TrimSpace(param))
+		if err != nil {
+			return strings.TrimSpace(name), nil
+		}
+		params = append(params, value)
+	}
+	return strings.TrimSpace(name), params
+}
+
+// normalizeColumnName returns the lower case column name without backticks
+func normalizeColumnName(name string) string {
+	return strings.ToLower(strings.Trim(name, "`"))
+}
@@ -0,0 +1,46 @@
+package query_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/goto/transformers/mc2mc/pkg/query"
+)
+
+func TestIsLossyCast(t *testing.T) {
+	t.Run("returns false for lossless casts", func(t *testing.T) {
+		casts := [][2]string{
+			{"BIGINT", "STRING"},
+			{"int", "BIGINT"},
+			{"BIGINT", "DECIMAL(38,18)"},
+			{"INT", "DOUBLE"},
+			{"FLOAT", "DOUBLE"},
+			{"DECIMAL(10,2)", "DECIMAL(38,18)"},
+			{"DATE", "DATETIME"},
+			{"DATETIME", "TIMESTAMP"},
+			{"VARCHAR(10)", "VARCHAR(20)"},
+			{"ARRAY<STRING>", "array<string>"},
+		}
+		for _, c := range casts {
+			assert.False(t, query.IsLossyCast(c[0], c[1]), "%s to %s", c[0], c[1])
+		}
+	})
+	t.Run("returns true for lossy casts", func(t *testing.T) {
+		casts := [][2]string{
+			{"STRING", "BIGINT"},
+			{"BIGINT", "INT"},
+			{"BIGINT", "DECIMAL(10,2)"},
+			{"BIGINT", "DOUBLE"},
+			{"DOUBLE", "BIGINT"},
+			{"DECIMAL(38,18)", "DECIMAL(10,2)"},
+			{"TIMESTAMP", "DATETIME"},
+			{"DATETIME", "DATE"},
+			{"VARCHAR(20)", "VARCHAR(10)"},
+			{"ARRAY<BIGINT>", "ARRAY<INT>"},
+		}
+		for _, c := range casts {
+			assert.True(t, query.IsLossyCast(c[0], c[1]), "%s to %s", c[0], c[1])
+		}
+	})
+}
@@ -0,0 +1,173 @@
+package query
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// ResolveOutputColumns returns the output column names of the last statement of the given query
+// based on its top level SELECT list. It returns false when the names can't be resolved statically,
+// for example when it selects * or an expression without alias.
+func ResolveOutputColumns(query string) ([]string, bool) {
+	var tokens []Token
+	for _, stmt := range ParseStatements(query) {
+		if stmt.Kind != StatementEmpty {
+			tokens = significantTokens(stmt.Tokens)
+		}
+	}
+
+	start := indexTopLevelKeyword(tokens, 0, "SELECT")
+	if start < 0 {
+		return nil, false
+	}
+	start = skipKeywords(tokens, start+1, "DISTINCT", "ALL")
+	end := indexTopLevelKeyword(tokens, start, "FROM")
+	if end < 0 {
+		end = len(tokens)
+	}
+
+	columns := []string{}
+	depth, itemStart := 0, start
+	for i := start; i <= end; i++ {
+		if i < end {
+			switch tokens[i].Value {
+			case "(":
+				depth++
+				continue
+			case ")":
+				depth--
+				continue
+			}
+			if depth > 0 || tokens[i].Value != "," {
+				continue
+			}
+		}
+		column, ok := resolveColumnName(tokens[itemStart:i])
+		if !ok {
+			return nil, false
+		}
+		columns = append(columns, column)
+		itemStart = i + 1
+	}
+	return columns, true
+}
+
+// resolveColumnName returns the name of a SELECT list item
+func resolveColumnName(item []Token) (string, bool) {
+	n := len(item)
+	if n == 0 || item[n-1].Value == "*" {
+		return "", false
+	}
+	last := item[n-1]
+	if last.Type != TokenWord && last.Type != TokenQuotedIdentifier {
+		return "", false
+	}
+	switch {
+	case n == 1: // column
+		return last.Value, true
+	case item[n-2].Value == ".": // qualified column
+		if n == 3 || (n > 3 && item[n-4].Value == ".") {
+			return last.Value, true
+		}
+		return "", false
+	case item[n-2].IsKeyword("AS"): // aliased expression
+		return last.Value, true
+	case item[n-2].Type == TokenWord || item[n-2].Type == TokenQuotedIdentifier || item[n-2].Value == ")":
+		return last.Value, true // alias without AS
+	}
+	return "", false
+}
+
+// constructColumnMapping validates the query output columns against the destination columns by name.
+// Missing nullable columns are filled with NULL casted to the destination type when enabled,
+// the fill values are added as overrided values so they're projected in place of the columns.
+func (b *Builder) constructColumnMapping(query string) error {
+	outputColumns := make([]string, len(b.queryColumns))
+	for i, column := range b.queryColumns {
+		outputColumns[i] = column.Name
+	}
+	if len(outputColumns) == 0 {
+		columns, ok := ResolveOutputColumns(query)
+		if !ok {
+			b.l.Warn("can't resolve query output columns, skipping column mapping validation")
+			return nil
+		}
+		outputColumns = columns
+	}
+
+	if err := b.fetchOrderedColumns(); err != nil {
+		return errors.WithStack(err)
+	}
+	partitionNames, err := b.client.GetPartitionNames(context.Background(), b.destinationTableID)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+
+	extra := []string{}
+	for _, column := range outputColumns {
+		if _, ok := findColumn(b.orderedColumns, column); ok {
+			continue
+		}
+		if _, ok := findColumn(partitionNames, column); ok {
+			continue
+		}
+		extra = append(extra, column)
+	}
+	// extra columns are added to destination when field addition is enabled
+	if len(extra) > 0 && !b.enableFieldAddition {
+		return errors.Errorf("query columns are not found in destination table %s: %s", b.destinationTableID, strings.Join(extra, ", "))
+	}
+
+	missing := []string{}
+	for _, column := range b.orderedColumns {
+		if _, ok := findColumn(outputColumns, column); ok {
+			continue
+		}
+		if _, ok := b.overridedValues[column]; ok {
+			continue
+		}
+		if _, ok := b.staticPartitionValue(column); ok {
+			continue
+		}
+		missing = append(missing, column)
+	}
+	if len(missing) == 0 {
+		return nil
+	}
+	if !b.fillMissingColumns {
+		return errors.Errorf("destination columns are missing in query for table %s: %s", b.destinationTableID, strings.Join(missing, ", "))
+	}
+	return errors.WithStack(b.fillColumns(missing))
+}
+
+// fillColumns fills the given destination columns with NULL casted to their types,
+// it fails when any of the columns is not nullable
+func (b *Builder) fillColumns(columns []string) error {
+	definitions, err := b.client.GetColumns(b.destinationTableID)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	if b.overridedValues == nil {
+		b.overridedValues = make(map[string]string)
+	}
+	for _, column := range columns {
+		var definition *Column
+		for i := range definitions {
+			if _, ok := findColumn([]string{definitions[i].Name}, column); ok {
+				definition = &definitions[i]
+				break
+			}
+		}
+		if definition == nil {
+			return errors.Errorf("type of column %s is not found in destination table %s", column, b.destinationTableID)
+		}
+		if definition.NotNull {
+			return errors.Errorf("destination column %s of table %s is not nullable and missing in query", column, b.destinationTableID)
+		}
+		b.overridedValues[column] = fmt.Sprintf("CAST(NULL AS %s)", definition.Type)
+	}
+	return nil
+}
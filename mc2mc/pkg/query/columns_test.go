@@ -0,0 +1,33 @@
+package query_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/goto/transformers/mc2mc/pkg/query"
+)
+
+func TestResolveOutputColumns(t *testing.T) {
+	t.Run("returns column names of select list", func(t *testing.T) {
+		q := `set odps.sql.allow.fullscan=true;
+WITH src AS (SELECT id, name FROM project.playground.table)
+SELECT DISTINCT s.id, UPPER(s.name) AS name, CONCAT(s.id, ',', s.name) label, ` + "`date`" + `, project.playground.table.dt
+FROM src s`
+		columns, ok := query.ResolveOutputColumns(q)
+		assert.True(t, ok)
+		assert.Equal(t, []string{"id", "name", "label", "`date`", "dt"}, columns)
+	})
+	t.Run("returns false when selecting all columns", func(t *testing.T) {
+		_, ok := query.ResolveOutputColumns(`SELECT t.* FROM project.playground.table t`)
+		assert.False(t, ok)
+	})
+	t.Run("returns false when expression has no alias", func(t *testing.T) {
+		_, ok := query.ResolveOutputColumns(`SELECT id, COUNT(1) FROM project.playground.table GROUP BY id`)
+		assert.False(t, ok)
+	})
+	t.Run("returns false for non select statement", func(t *testing.T) {
+		_, ok := query.ResolveOutputColumns(`DROP TABLE project.playground.table`)
+		assert.False(t, ok)
+	})
+}
@@ -0,0 +1,99 @@
+package query
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Column is a column definition of a table
+type Column struct {
+	Name    string
+	Type    string
+	NotNull bool
+}
+
+// TableSpec is the specification of a table created automatically
+type TableSpec struct {
+	PartitionColumns []Column
+	Lifecycle        int
+	Comment          string
+}
+
+// ParseColumns parses column definitions in name[:type] format,
+// type is STRING when it's not specified
+func ParseColumns(definitions []string) []Column {
+	columns := make([]Column, 0, len(definitions))
+	for _, definition := range definitions {
+		definition = strings.TrimSpace(definition)
+		if definition == "" {
+			continue
+		}
+		name, columnType, ok := strings.Cut(definition, ":")
+		if !ok || strings.TrimSpace(columnType) == "" {
+			columnType = "STRING"
+		}
+		columns = append(columns, Column{Name: strings.TrimSpace(name), Type: strings.ToUpper(strings.TrimSpace(columnType))})
+	}
+	return columns
+}
+
+// ConstructSchemaInferenceQuery constructs CREATE TABLE AS SELECT query with no rows,
+// so the result schema of the given query can be read from the created table.
+// Headers, variables and udfs are kept since the query may depend on them,
+// only the first query is used when it's separated by break marker,
+// and the table only lives for a day in case it's not dropped.
+func ConstructSchemaInferenceQuery(tableID, query string) string {
+	query = strings.Split(query, BREAK_MARKER)[0]
+	headers, stmts := separateStatements(ParseStatements(query), Statement.IsHeader)
+	varsAndUDFs, stmts := separateStatements(stmts, Statement.IsDeclaration)
+	_, stmts = separateStatements(stmts, Statement.IsDDL)
+
+	builder := strings.Builder{}
+	if hr := joinStatements(headers, true); hr != "" {
+		builder.WriteString(fmt.Sprintf("%s\n", hr))
+	}
+	if vars := joinStatements(varsAndUDFs, true); vars != "" {
+		builder.WriteString(fmt.Sprintf("%s\n", vars))
+	}
+	// the result comes from the last statement
+	resultQuery := ""
+	for i := len(stmts) - 1; i >= 0 && resultQuery == ""; i-- {
+		if stmts[i].Kind != StatementEmpty {
+			resultQuery = stmts[i].Text
+		}
+	}
+	builder.WriteString(fmt.Sprintf("CREATE TABLE IF NOT EXISTS %s LIFECYCLE 1 AS\nSELECT * FROM (\n%s\n) LIMIT 0\n;", tableID, resultQuery))
+	return builder.String()
+}
+
+// ConstructCreateTableQuery constructs CREATE TABLE query with the given columns and spec,
+// columns which are also partition columns are only defined as partition
+func ConstructCreateTableQuery(tableID string, columns []Column, spec TableSpec) string {
+	partitionNames := make([]string, len(spec.PartitionColumns))
+	partitions := make([]string, len(spec.PartitionColumns))
+	for i, column := range spec.PartitionColumns {
+		partitionNames[i] = column.Name
+		partitions[i] = fmt.Sprintf("%s %s", column.Name, column.Type)
+	}
+	definitions := []string{}
+	for _, column := range columns {
+		if _, ok := findColumn(partitionNames, column.Name); ok {
+			continue
+		}
+		definitions = append(definitions, fmt.Sprintf("%s %s", column.Name, column.Type))
+	}
+
+	builder := strings.Builder{}
+	builder.WriteString(fmt.Sprintf("CREATE TABLE IF NOT EXISTS %s (\n  %s\n)", tableID, strings.Join(definitions, ",\n  ")))
+	if spec.Comment != "" {
+		builder.WriteString(fmt.Sprintf("\nCOMMENT '%s'", strings.ReplaceAll(spec.Comment, "'", "\\'")))
+	}
+	if len(partitions) > 0 {
+		builder.WriteString(fmt.Sprintf("\nPARTITIONED BY (%s)", strings.Join(partitions, ", ")))
+	}
+	if spec.Lifecycle > 0 {
+		builder.WriteString(fmt.Sprintf("\nLIFECYCLE %d", spec.Lifecycle))
+	}
+	builder.WriteString("\n;")
+	return builder.String()
+}
@@ -0,0 +1,74 @@
+package query_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/goto/transformers/mc2mc/pkg/query"
+)
+
+func TestParseColumns(t *testing.T) {
+	t.Run("returns columns with string type as default", func(t *testing.T) {
+		columns := query.ParseColumns([]string{"dt", " hour:bigint ", ""})
+		assert.Equal(t, []query.Column{
+			{Name: "dt", Type: "STRING"},
+			{Name: "hour", Type: "BIGINT"},
+		}, columns)
+	})
+}
+
+func TestConstructSchemaInferenceQuery(t *testing.T) {
+	t.Run("returns empty create table as select of the last query with headers and variables", func(t *testing.T) {
+		raw := `set odps.sql.allow.fullscan=true;
+@src := SELECT id FROM project.playground.source;
+DROP TABLE IF EXISTS project.playground.tmp;
+SELECT id, name FROM @src;
+-- trailing comment`
+
+		actual := query.ConstructSchemaInferenceQuery("project.playground.table_schema", raw)
+		assert.Equal(t, `set odps.sql.allow.fullscan=true
+;
+@src := SELECT id FROM project.playground.source
+;
+CREATE TABLE IF NOT EXISTS project.playground.table_schema LIFECYCLE 1 AS
+SELECT * FROM (
+SELECT id, name FROM @src
+) LIMIT 0
+;`, actual)
+	})
+	t.Run("uses the first query when it contains break marker", func(t *testing.T) {
+		raw := "SELECT 1 AS a\n" + query.BREAK_MARKER + "\nSELECT 2 AS a"
+
+		actual := query.ConstructSchemaInferenceQuery("project.playground.table_schema", raw)
+		assert.Equal(t, `CREATE TABLE IF NOT EXISTS project.playground.table_schema LIFECYCLE 1 AS
+SELECT * FROM (
+SELECT 1 AS a
+) LIMIT 0
+;`, actual)
+	})
+}
+
+func TestConstructCreateTableQuery(t *testing.T) {
+	t.Run("returns create table query with partition, comment and lifecycle", func(t *testing.T) {
+		columns := []query.Column{{Name: "id", Type: "BIGINT"}, {Name: "`date`", Type: "DATE"}, {Name: "dt", Type: "STRING"}}
+
+		actual := query.ConstructCreateTableQuery("project.playground.table", columns, query.TableSpec{
+			PartitionColumns: []query.Column{{Name: "dt", Type: "STRING"}},
+			Lifecycle:        30,
+			Comment:          "created by mc2mc's auto create",
+		})
+		assert.Equal(t, `CREATE TABLE IF NOT EXISTS project.playground.table (
+  id BIGINT,
+  `+"`date`"+` DATE
+)
+COMMENT 'created by mc2mc\'s auto create'
+PARTITIONED BY (dt STRING)
+LIFECYCLE 30
+;`, actual)
+	})
+	t.Run("returns create table query for non partitioned table", func(t *testing.T) {
+		actual := query.ConstructCreateTableQuery("project.playground.table", []query.Column{{Name: "id", Type: "BIGINT"}}, query.TableSpec{})
+		assert.Equal(t, "CREATE TABLE IF NOT EXISTS project.playground.table (\n  id BIGINT\n)\n;", actual)
+	})
+}
@@ -0,0 +1,92 @@
+package query
+
+import "strings"
+
+// tableAccess is the set of tables read and written by a query,
+// barrier is set when the tables of any statement can't be inferred
+type tableAccess struct {
+	reads   map[string]bool
+	writes  map[string]bool
+	barrier bool
+}
+
+// Dependencies returns the indexes of the earlier queries which each query depends on.
+// A query depends on an earlier one when it reads, writes or drops a table written by the earlier one,
+// or writes a table read by the earlier one. Tables are matched by name regardless of project and schema,
+// so ambiguous names are kept in order. A query with a statement whose tables can't be inferred
+// depends on every earlier query and every later query depends on it.
+func Dependencies(queries []string) [][]int {
+	accesses := make([]tableAccess, len(queries))
+	for i, query := range queries {
+		accesses[i] = queryTableAccess(query)
+	}
+
+	dependencies := make([][]int, len(queries))
+	for j := range queries {
+		dependencies[j] = []int{}
+		for i := 0; i < j; i++ {
+			earlier, later := accesses[i], accesses[j]
+			if earlier.barrier || later.barrier ||
+				intersects(earlier.writes, later.reads) ||
+				intersects(earlier.writes, later.writes) ||
+				intersects(earlier.reads, later.writes) {
+				dependencies[j] = append(dependencies[j], i)
+			}
+		}
+	}
+	return dependencies
+}
+
+// queryTableAccess returns the tables read and written by the statements of the query
+func queryTableAccess(query string) tableAccess {
+	access := tableAccess{reads: map[string]bool{}, writes: map[string]bool{}}
+	for _, stmt := range ParseStatements(query) {
+		stmt = explainedStatement(stmt)
+		lineage := stmt.Lineage()
+		switch stmt.Kind {
+		case StatementEmpty, StatementSet, StatementVariable, StatementSelect:
+		case StatementUnknown, StatementFunction:
+			access.barrier = true
+		default:
+			if len(lineage.Targets) == 0 { // writing statement with unknown target
+				access.barrier = true
+			}
+		}
+		for _, source := range lineage.Sources {
+			access.reads[tableName(source)] = true
+		}
+		for _, target := range lineage.Targets {
+			access.writes[tableName(target)] = true
+		}
+	}
+	return access
+}
+
+// explainedStatement returns the statement explained on dry run, so the queries of dry run
+// have the same dependencies as when they're executed. Other statements are returned as is.
+func explainedStatement(stmt Statement) Statement {
+	tokens := significantTokens(stmt.Tokens)
+	if len(tokens) == 0 || !tokens[0].IsKeyword("EXPLAIN") {
+		return stmt
+	}
+	explained := ParseStatements(stmt.Text[tokens[0].End-stmt.Start:])
+	if len(explained) == 0 {
+		return stmt
+	}
+	return explained[0]
+}
+
+// tableName returns the lower cased table name without project and schema
+func tableName(tableID string) string {
+	parts := strings.Split(NormalizeTableName(tableID), ".")
+	return strings.ToLower(parts[len(parts)-1])
+}
+
+func intersects(a, b map[string]bool) bool {
+	for k := range a {
+		if b[k] {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,58 @@
+package query_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/goto/transformers/mc2mc/pkg/query"
+)
+
+func TestDependencies(t *testing.T) {
+	t.Run("returns no dependencies for statements writing independent tables", func(t *testing.T) {
+		dependencies := query.Dependencies([]string{
+			"INSERT OVERWRITE TABLE project.playground.a SELECT * FROM project.playground.src_a\n;",
+			"INSERT OVERWRITE TABLE project.playground.b SELECT * FROM project.playground.src_b\n;",
+		})
+		assert.Equal(t, [][]int{{}, {}}, dependencies)
+	})
+	t.Run("returns dependency when statement reads or drops a table written before", func(t *testing.T) {
+		dependencies := query.Dependencies([]string{
+			"CREATE TABLE project.playground.tmp AS SELECT * FROM project.playground.src\n;",
+			"INSERT INTO TABLE project.playground.a SELECT * FROM tmp\n;",
+			"INSERT INTO TABLE project.playground.b SELECT * FROM project.playground.other\n;",
+			"DROP TABLE IF EXISTS project.playground.tmp\n;",
+		})
+		assert.Equal(t, [][]int{{}, {0}, {}, {0, 1}}, dependencies)
+	})
+	t.Run("returns dependency when statement writes a table read before", func(t *testing.T) {
+		dependencies := query.Dependencies([]string{
+			"INSERT INTO TABLE project.playground.a SELECT * FROM project.playground.src\n;",
+			"INSERT OVERWRITE TABLE project.playground.src SELECT 1 as id\n;",
+		})
+		assert.Equal(t, [][]int{{}, {0}}, dependencies)
+	})
+	t.Run("returns dependency through variable sources", func(t *testing.T) {
+		dependencies := query.Dependencies([]string{
+			"INSERT OVERWRITE TABLE project.playground.src SELECT 1 as id\n;",
+			"@v := SELECT id FROM project.playground.src;\nINSERT INTO TABLE project.playground.a SELECT * FROM @v\n;",
+		})
+		assert.Equal(t, [][]int{{}, {0}}, dependencies)
+	})
+	t.Run("returns dependencies on statement whose tables can't be inferred", func(t *testing.T) {
+		dependencies := query.Dependencies([]string{
+			"INSERT OVERWRITE TABLE project.playground.a SELECT 1 as id\n;",
+			"GRANT SELECT ON TABLE project.playground.a TO USER someone\n;",
+			"INSERT OVERWRITE TABLE project.playground.b SELECT 1 as id\n;",
+		})
+		assert.Equal(t, [][]int{{}, {0}, {1}}, dependencies)
+	})
+	t.Run("returns dependencies of explained statements of dry run", func(t *testing.T) {
+		dependencies := query.Dependencies([]string{
+			"EXPLAIN\nCREATE TABLE project.playground.tmp AS SELECT 1 as id\n;",
+			"EXPLAIN\nINSERT INTO TABLE project.playground.a SELECT * FROM project.playground.tmp\n;",
+			"-- header\nEXPLAIN\nINSERT INTO TABLE project.playground.b SELECT 1 as id\n;",
+		})
+		assert.Equal(t, [][]int{{}, {0}, {}}, dependencies)
+	})
+}
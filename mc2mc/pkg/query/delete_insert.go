@@ -0,0 +1,78 @@
+package query
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// constructDeleteInsertQuery constructs INSERT OVERWRITE query which removes the destination rows
+// matching the delete predicate within the target partitions and inserts the query result,
+// it doesn't rely on DELETE or MERGE INTO so it works for non transactional table as well.
+// Target partitions are the partitions of the query result, a partition is overwritten
+// with its remaining rows and the new rows in a single statement to keep it atomic.
+// Only the target partitions are read back from the destination, so the destination
+// must be partitioned, otherwise the whole table would be rewritten.
+func (b *Builder) constructDeleteInsertQuery(query string) (string, error) {
+	if b.deletePredicate == "" {
+		return "", errors.New("delete predicate is required for delete insert method")
+	}
+
+	if err := b.fetchOrderedColumns(); err != nil {
+		return "", errors.WithStack(err)
+	}
+	partitionNames, err := b.client.GetPartitionNames(context.Background(), b.destinationTableID)
+	if err != nil {
+		return "", errors.WithStack(err)
+	}
+	if len(partitionNames) == 0 {
+		return "", errors.Errorf("delete insert method requires partitioned destination table, %s is not partitioned", b.destinationTableID)
+	}
+
+	// partition columns are selected last for dynamic partition insert
+	columns := append([]string{}, b.orderedColumns...)
+	for _, partitionName := range partitionNames {
+		if _, ok := findColumn(columns, partitionName); !ok {
+			columns = append(columns, partitionName)
+		}
+	}
+
+	projections := make([]string, len(columns))
+	remainingColumns := make([]string, len(columns))
+	for i, column := range columns {
+		projections[i] = column
+		if val, ok := b.overridedValues[column]; ok {
+			projections[i] = fmt.Sprintf("%s as %s", val, column)
+		}
+		remainingColumns[i] = fmt.Sprintf("t.%s", column)
+	}
+
+	builder := strings.Builder{}
+	builder.WriteString(fmt.Sprintf("WITH __source AS (\nSELECT %s FROM (\n%s\n)\n)\n", strings.Join(projections, ", "), query))
+	if b.enableAutoPartition {
+		builder.WriteString(fmt.Sprintf("INSERT OVERWRITE TABLE %s \n", b.destinationTableID))
+	} else {
+		builder.WriteString(fmt.Sprintf("INSERT OVERWRITE TABLE %s PARTITION (%s) \n", b.destinationTableID, strings.Join(partitionNames, ", ")))
+	}
+
+	// keep the destination rows of the target partitions which don't match the predicate,
+	// filtering each partition column prunes the read back to the target partitions
+	filters := make([]string, len(partitionNames))
+	conditions := make([]string, len(partitionNames))
+	for i, partitionName := range partitionNames {
+		filters[i] = fmt.Sprintf("%s IN (SELECT DISTINCT %s FROM __source)", partitionName, partitionName)
+		conditions[i] = fmt.Sprintf("t.%s = p.%s", partitionName, partitionName)
+	}
+	builder.WriteString(fmt.Sprintf("SELECT %s FROM (\nSELECT * FROM %s WHERE %s AND NOT COALESCE((%s), FALSE)\n) AS t\n",
+		strings.Join(remainingColumns, ", "), b.destinationTableID, strings.Join(filters, " AND "), b.deletePredicate))
+	if len(partitionNames) > 1 {
+		// filters of multi level partitions match other combinations of the values as well,
+		// so only the exact target partitions are kept
+		builder.WriteString(fmt.Sprintf("LEFT SEMI JOIN (SELECT DISTINCT %s FROM __source) AS p ON %s\n",
+			strings.Join(partitionNames, ", "), strings.Join(conditions, " AND ")))
+	}
+	builder.WriteString(fmt.Sprintf("UNION ALL\nSELECT %s FROM __source\n;", strings.Join(columns, ", ")))
+	return builder.String(), nil
+}
@@ -0,0 +1,131 @@
+package query
+
+import (
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// Granularity is the step of the windows the queries are generated for
+type Granularity uint8
+
+const (
+	DAY Granularity = iota
+	HOUR
+	WEEK
+	MONTH
+	AUTO // detected from partition columns
+)
+
+var granularityNames = map[Granularity]string{
+	DAY:   "DAY",
+	HOUR:  "HOUR",
+	WEEK:  "WEEK",
+	MONTH: "MONTH",
+	AUTO:  "AUTO",
+}
+
+func (g Granularity) String() string {
+	return granularityNames[g]
+}
+
+// ParseGranularity returns the granularity of the given name, empty name is DAY
+func ParseGranularity(name string) (Granularity, error) {
+	if name == "" {
+		return DAY, nil
+	}
+	for granularity, granularityName := range granularityNames {
+		if strings.EqualFold(name, granularityName) {
+			return granularity, nil
+		}
+	}
+	return DAY, errors.Errorf("not supported granularity: %s", name)
+}
+
+// Truncate returns the start of the window containing the given time,
+// weeks start on Monday
+func (g Granularity) Truncate(t time.Time) time.Time {
+	switch g {
+	case HOUR:
+		return time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), 0, 0, 0, t.Location())
+	case WEEK:
+		day := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location())
+		return day.AddDate(0, 0, -(int(day.Weekday())+6)%7)
+	case MONTH:
+		return time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, t.Location())
+	default:
+		return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location())
+	}
+}
+
+// Next returns the start of the next window, calendar based steps
+// are used so it's not affected by daylight saving time
+func (g Granularity) Next(t time.Time) time.Time {
+	switch g {
+	case HOUR:
+		return t.Add(time.Hour)
+	case WEEK:
+		return t.AddDate(0, 0, 7)
+	case MONTH:
+		return t.AddDate(0, 1, 0)
+	default:
+		return t.AddDate(0, 0, 1)
+	}
+}
+
+// GenerateWindows returns the start of each window within the given range. DAY keeps the
+// original behaviour: daily steps from the given start which is a single window when
+// the range is not longer than 24 hours. Other granularities are aligned to their window,
+// the range is a single window when it's not longer than a window.
+func GenerateWindows(start, end time.Time, g Granularity) []time.Time {
+	single := end.Sub(start) <= 24*time.Hour
+	if g != DAY {
+		start = g.Truncate(start)
+		single = !end.After(g.Next(start))
+	}
+	if single {
+		return []time.Time{start}
+	}
+	windows := []time.Time{}
+	for t := start; t.Before(end); t = g.Next(t) {
+		windows = append(windows, t)
+	}
+	return windows
+}
+
+// DetectGranularity detects the granularity from partition columns of the destination table
+// and their configured values, it returns DAY when nothing finer or coarser is found
+func DetectGranularity(partitionNames []string, partitionValues map[string]string) Granularity {
+	for _, value := range partitionValues {
+		kind, layout, _ := strings.Cut(strings.TrimSpace(value), ":")
+		switch strings.ToLower(kind) {
+		case "hour":
+			return HOUR
+		case "time", "date":
+			switch {
+			case strings.Contains(layout, "15"):
+				return HOUR
+			case strings.Contains(layout, "01") && !strings.Contains(layout, "02"):
+				return MONTH
+			}
+		}
+	}
+	for _, partitionName := range partitionNames {
+		switch strings.ToLower(strings.Trim(partitionName, "`")) {
+		case "hh", "hour", "_partitionhour":
+			return HOUR
+		}
+	}
+	for _, partitionName := range partitionNames {
+		switch strings.ToLower(strings.Trim(partitionName, "`")) {
+		case "dt", "date", "ds", "day", "_partitiondate", "_partitiontime":
+			return DAY
+		case "week":
+			return WEEK
+		case "month":
+			return MONTH
+		}
+	}
+	return DAY
+}
@@ -0,0 +1,105 @@
+package query_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/goto/transformers/mc2mc/pkg/query"
+)
+
+func TestParseGranularity(t *testing.T) {
+	t.Run("returns day for empty granularity", func(t *testing.T) {
+		g, err := query.ParseGranularity("")
+		assert.NoError(t, err)
+		assert.Equal(t, query.DAY, g)
+	})
+	t.Run("returns granularity case insensitively", func(t *testing.T) {
+		g, err := query.ParseGranularity("hour")
+		assert.NoError(t, err)
+		assert.Equal(t, query.HOUR, g)
+	})
+	t.Run("returns error for unknown granularity", func(t *testing.T) {
+		_, err := query.ParseGranularity("minute")
+		assert.ErrorContains(t, err, "not supported granularity: minute")
+	})
+}
+
+func TestGranularity_Truncate(t *testing.T) {
+	t.Run("truncates to monday for week", func(t *testing.T) {
+		actual := query.WEEK.Truncate(time.Date(2024, 1, 7, 10, 0, 0, 0, time.UTC)) // sunday
+		assert.Equal(t, time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC), actual)
+	})
+	t.Run("truncates to first day for month", func(t *testing.T) {
+		actual := query.MONTH.Truncate(time.Date(2024, 2, 29, 10, 0, 0, 0, time.UTC))
+		assert.Equal(t, time.Date(2024, 2, 1, 0, 0, 0, 0, time.UTC), actual)
+	})
+}
+
+func TestGenerateWindows(t *testing.T) {
+	t.Run("returns single window when range is not longer than a window", func(t *testing.T) {
+		start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+		windows := query.GenerateWindows(start, start.AddDate(0, 0, 1), query.DAY)
+		assert.Equal(t, []time.Time{start}, windows)
+	})
+	t.Run("returns daily windows from unaligned start", func(t *testing.T) {
+		start := time.Date(2024, 1, 1, 5, 0, 0, 0, time.UTC)
+		windows := query.GenerateWindows(start, start.AddDate(0, 0, 2), query.DAY)
+		assert.Equal(t, []time.Time{
+			time.Date(2024, 1, 1, 5, 0, 0, 0, time.UTC),
+			time.Date(2024, 1, 2, 5, 0, 0, 0, time.UTC),
+		}, windows)
+	})
+	t.Run("returns unaligned start as single daily window", func(t *testing.T) {
+		start := time.Date(2024, 1, 1, 5, 0, 0, 0, time.UTC)
+		windows := query.GenerateWindows(start, start.Add(24*time.Hour), query.DAY)
+		assert.Equal(t, []time.Time{start}, windows)
+	})
+	t.Run("returns hourly windows", func(t *testing.T) {
+		start := time.Date(2024, 1, 1, 22, 30, 0, 0, time.UTC)
+		windows := query.GenerateWindows(start, start.Add(3*time.Hour), query.HOUR)
+		assert.Equal(t, []time.Time{
+			time.Date(2024, 1, 1, 22, 0, 0, 0, time.UTC),
+			time.Date(2024, 1, 1, 23, 0, 0, 0, time.UTC),
+			time.Date(2024, 1, 2, 0, 0, 0, 0, time.UTC),
+			time.Date(2024, 1, 2, 1, 0, 0, 0, time.UTC),
+		}, windows)
+	})
+	t.Run("returns daily windows at midnight across daylight saving time", func(t *testing.T) {
+		amsterdam, err := time.LoadLocation("Europe/Amsterdam")
+		assert.NoError(t, err)
+		start := time.Date(2024, 3, 30, 0, 0, 0, 0, amsterdam)
+		windows := query.GenerateWindows(start, time.Date(2024, 4, 1, 0, 0, 0, 0, amsterdam), query.DAY)
+		assert.Equal(t, []time.Time{
+			time.Date(2024, 3, 30, 0, 0, 0, 0, amsterdam),
+			time.Date(2024, 3, 31, 0, 0, 0, 0, amsterdam),
+		}, windows)
+	})
+	t.Run("returns monthly windows aligned to the first day", func(t *testing.T) {
+		start := time.Date(2024, 1, 15, 0, 0, 0, 0, time.UTC)
+		windows := query.GenerateWindows(start, time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC), query.MONTH)
+		assert.Equal(t, []time.Time{
+			time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC),
+			time.Date(2024, 2, 1, 0, 0, 0, 0, time.UTC),
+		}, windows)
+	})
+}
+
+func TestDetectGranularity(t *testing.T) {
+	t.Run("detects hour from partition value", func(t *testing.T) {
+		assert.Equal(t, query.HOUR, query.DetectGranularity([]string{"dt", "ts"}, map[string]string{"ts": "time:2006010215"}))
+	})
+	t.Run("detects month from partition value layout", func(t *testing.T) {
+		assert.Equal(t, query.MONTH, query.DetectGranularity([]string{"ym"}, map[string]string{"ym": "date:200601"}))
+	})
+	t.Run("detects hour from partition name", func(t *testing.T) {
+		assert.Equal(t, query.HOUR, query.DetectGranularity([]string{"dt", "hh"}, nil))
+	})
+	t.Run("detects month from partition name", func(t *testing.T) {
+		assert.Equal(t, query.MONTH, query.DetectGranularity([]string{"month"}, nil))
+	})
+	t.Run("returns day by default", func(t *testing.T) {
+		assert.Equal(t, query.DAY, query.DetectGranularity([]string{"region"}, nil))
+	})
+}
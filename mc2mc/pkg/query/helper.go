@@ -2,7 +2,6 @@ package query
 
 import (
 	"fmt"
-	"regexp"
 	"strings"
 )
 
@@ -10,46 +9,34 @@ const (
 	BREAK_MARKER = "--*--optimus-break-marker--*--"
 )
 
-var (
-	semicolonPattern    = regexp.MustCompile(`;\s*(\n+|$)`)                         // regex to match semicolons
-	commentPattern      = regexp.MustCompile(`--[^\n]*`)                            // regex to match comments
-	multiCommentPattern = regexp.MustCompile(`(?s)/\*.*?\*/`)                       // regex to match multi-line comments
-	headerPattern       = regexp.MustCompile(`(?i)^set`)                            // regex to match header statements
-	variablePattern     = regexp.MustCompile(`(?i)^@`)                              // regex to match variable statements
-	dropPattern         = regexp.MustCompile(`(?i)^DROP\s+`)                        // regex to match DROP statements
-	udfPattern          = regexp.MustCompile(`(?i)^function\s+`)                    // regex to match UDF statements
-	ddlPattern          = regexp.MustCompile(`(?i)^(ALTER|DROP|TRUNCATE)\s+`)       // regex to match DDL statements
-	ddlCreatePattern    = regexp.MustCompile(`(?i)^(CREATE\s+TABLE\s+[^\s]+\s*\()`) // regex to match CREATE DDL statements
-	stringPattern       = regexp.MustCompile(`'[^']*'`)                             // regex to match SQL strings (anything inside single quotes)
-)
-
 func SplitQueryComponents(query string) (headers []string, varsUDFs []string, queries []string) {
-	query = strings.TrimSpace(query)
+	headers, varsUDFs, stmts := splitStatementComponents(ParseStatements(query))
+	for _, stmt := range stmts {
+		queries = append(queries, stmt.Text)
+	}
+	return headers, varsUDFs, queries
+}
 
-	// extract all header, variable and query lines
-	stmts := semicolonPattern.Split(query, -1)
+// splitStatementComponents groups headers, variables and udfs that precede each
+// executable statement, the i-th headers and varsUDFs belong to the i-th statement
+func splitStatementComponents(stmts []Statement) (headers []string, varsUDFs []string, queries []Statement) {
 	queryIndex := 0
 	for _, stmt := range stmts {
-		stmt = strings.TrimSpace(stmt)
-		if stmt == "" {
-			continue
-		}
-		stmtWithoutComment := RemoveComments(stmt)
-		if headerPattern.MatchString(strings.TrimSpace(stmtWithoutComment)) {
+		switch {
+		case stmt.IsHeader():
 			for len(headers) <= queryIndex {
 				headers = append(headers, "")
 			}
-			headers[queryIndex] += strings.TrimSpace(stmt) + "\n;\n"
-		} else if variablePattern.MatchString(strings.TrimSpace(stmtWithoutComment)) ||
-			udfPattern.MatchString(strings.TrimSpace(stmtWithoutComment)) {
+			headers[queryIndex] += stmt.Text + "\n;\n"
+		case stmt.IsDeclaration():
 			for len(varsUDFs) <= queryIndex {
 				varsUDFs = append(varsUDFs, "")
 			}
-			varsUDFs[queryIndex] += strings.TrimSpace(stmt) + "\n;\n"
-		} else if strings.TrimSpace(stmtWithoutComment) == "" {
+			varsUDFs[queryIndex] += stmt.Text + "\n;\n"
+		case stmt.Kind == StatementEmpty:
 			// if the statement is empty, it's a comment, then omit it
 			// since it doesn't make sense to execute this statement
-		} else {
+		default:
 			queries = append(queries, stmt)
 			queryIndex++
 		}
@@ -65,7 +52,6 @@ func SplitQueryComponents(query string) (headers []string, varsUDFs []string, qu
 		}
 		headers[i] = strings.TrimSpace(headers[i])
 		varsUDFs[i] = strings.TrimSpace(varsUDFs[i])
-		queries[i] = strings.TrimSpace(queries[i])
 	}
 
 	return headers, varsUDFs, queries
@@ -88,127 +74,92 @@ func JoinSliceString(slice []string, delimiter string) string {
 }
 
 func SeparateHeadersAndQuery(query string) (string, string) {
-	headers := []string{}
-	query = strings.TrimSpace(query)
-	remainingQueries := []string{}
+	headers, remainingQueries := separateStatements(ParseStatements(query), Statement.IsHeader)
+	return joinStatements(headers, true), joinStatements(remainingQueries, false)
+}
 
-	// extract all header lines (set statements)
-	stmts := semicolonPattern.Split(query, -1)
-	for _, stmt := range stmts {
-		stmt = strings.TrimSpace(stmt)
-		if stmt == "" {
-			continue
-		}
-		stmtWithoutComment := RemoveComments(stmt)
-		if headerPattern.MatchString(strings.TrimSpace(stmtWithoutComment)) {
-			headers = append(headers, stmt)
-		} else if strings.TrimSpace(stmtWithoutComment) == "" {
-			// if the statement is empty, it's a comment, then omit it
-			// since it doesn't make sense to execute this statement
-			continue
-		} else {
-			remainingQueries = append(remainingQueries, stmt)
-		}
-	}
+func SeparateVariablesUDFsAndQuery(query string) (string, string) {
+	variablesAndUDFs, remainingQueries := separateStatements(ParseStatements(query), Statement.IsDeclaration)
+	return joinStatements(variablesAndUDFs, true), joinStatements(remainingQueries, false)
+}
 
-	headerStr := ""
-	if len(headers) > 0 {
-		for i, header := range headers {
-			headers[i] = strings.TrimSpace(header)
-		}
-		headerStr = strings.Join(headers, "\n;\n")
-		headerStr += "\n;"
+func SeparateDropsAndQuery(query string) ([]string, string) {
+	drops, remainingQueries := separateStatements(ParseStatements(query), func(stmt Statement) bool {
+		return stmt.Kind == StatementDrop
+	})
+	dropStrs := make([]string, len(drops))
+	for i, drop := range drops {
+		dropStrs[i] = drop.Text
 	}
-
-	// join the remaining queries back together
-	queryStr := strings.Join(remainingQueries, "\n;\n")
-
-	return headerStr, queryStr
+	return dropStrs, joinStatements(remainingQueries, false)
 }
 
-func SeparateVariablesUDFsAndQuery(query string) (string, string) {
-	variablesAndUDFs := []string{}
-	query = strings.TrimSpace(query)
-	remainingQueries := []string{}
-
-	// extract all variable lines (@ statements and comments)
-	stmts := semicolonPattern.Split(query, -1)
+// separateStatements separates statements matching the given predicate from the rest,
+// comment only statements are omitted since it doesn't make sense to execute them
+func separateStatements(stmts []Statement, match func(Statement) bool) ([]Statement, []Statement) {
+	matched := []Statement{}
+	remaining := []Statement{}
 	for _, stmt := range stmts {
-		stmt = strings.TrimSpace(stmt)
-		if stmt == "" {
+		switch {
+		case stmt.Kind == StatementEmpty:
 			continue
-		}
-		stmtWithoutComment := RemoveComments(stmt)
-		if variablePattern.MatchString(strings.TrimSpace(stmtWithoutComment)) ||
-			udfPattern.MatchString(strings.TrimSpace(stmtWithoutComment)) {
-			variablesAndUDFs = append(variablesAndUDFs, stmt)
-		} else if strings.TrimSpace(stmtWithoutComment) == "" {
-			// if the statement is empty, it's a comment, then omit it
-			// since it doesn't make sense to execute this statement
-		} else {
-			remainingQueries = append(remainingQueries, stmt)
+		case match(stmt):
+			matched = append(matched, stmt)
+		default:
+			remaining = append(remaining, stmt)
 		}
 	}
+	return matched, remaining
+}
 
-	variableUDFStr := ""
-	if len(variablesAndUDFs) > 0 {
-		for i, variable := range variablesAndUDFs {
-			variablesAndUDFs[i] = strings.TrimSpace(variable)
-		}
-		variableUDFStr = strings.Join(variablesAndUDFs, "\n;\n")
-		variableUDFStr += "\n;"
+// joinStatements joins the statements back together with semicolons,
+// terminated adds semicolon after the last statement
+func joinStatements(stmts []Statement, terminated bool) string {
+	if len(stmts) == 0 {
+		return ""
 	}
-
-	// join the remaining queries back together
-	queryStr := strings.Join(remainingQueries, "\n;\n")
-
-	return variableUDFStr, queryStr
+	texts := make([]string, len(stmts))
+	for i, stmt := range stmts {
+		texts[i] = stmt.Text
+	}
+	joined := strings.Join(texts, "\n;\n")
+	if terminated {
+		joined += "\n;"
+	}
+	return joined
 }
 
-func SeparateDropsAndQuery(query string) ([]string, string) {
-	drops := []string{}
-	query = strings.TrimSpace(query)
-	remainingQueries := []string{}
+// RemoveComments removes line and block comments from the given query,
+// comment markers inside strings and identifiers are left untouched
+func RemoveComments(query string) string {
+	return removeComments(Tokenize(query))
+}
 
-	// extract all drop lines
-	stmts := semicolonPattern.Split(query, -1)
-	for _, stmt := range stmts {
-		stmt = strings.TrimSpace(stmt)
-		if stmt == "" {
+func removeComments(tokens []Token) string {
+	builder := strings.Builder{}
+	for _, t := range tokens {
+		if t.IsComment() {
 			continue
 		}
-		stmtWithoutComment := RemoveComments(stmt)
-		if dropPattern.MatchString(strings.TrimSpace(stmtWithoutComment)) {
-			drops = append(drops, strings.TrimSpace(stmt))
-		} else if strings.TrimSpace(stmtWithoutComment) == "" {
-			// if the statement is empty, it's a comment, then omit it
-			// since it doesn't make sense to execute this statement
-		} else {
-			remainingQueries = append(remainingQueries, stmt)
-		}
+		builder.WriteString(t.Value)
 	}
-
-	// join the remaining queries back together
-	queryStr := strings.Join(remainingQueries, "\n;\n")
-
-	return drops, queryStr
-}
-
-func RemoveComments(query string) string {
-	query = commentPattern.ReplaceAllString(query, "")
-	query = multiCommentPattern.ReplaceAllString(query, "")
-	return query
+	return builder.String()
 }
 
 func ProtectedStringLiteral(query string) (map[string]string, string) {
 	// Replace all strings with a placeholder to protect them
 	placeholders := make(map[string]string)
-	protectedQuery := stringPattern.ReplaceAllStringFunc(query, func(match string) string {
+	builder := strings.Builder{}
+	for _, t := range Tokenize(query) {
+		if t.Type != TokenString {
+			builder.WriteString(t.Value)
+			continue
+		}
 		placeholder := fmt.Sprintf("__STRING_PLACEHOLDER_%d__", len(placeholders))
-		placeholders[placeholder] = match
-		return placeholder
-	})
-	return placeholders, protectedQuery
+		placeholders[placeholder] = t.Value
+		builder.WriteString(placeholder)
+	}
+	return placeholders, builder.String()
 }
 
 func RestoreStringLiteral(query string, placeholders map[string]string) string {
@@ -219,7 +170,13 @@ func RestoreStringLiteral(query string, placeholders map[string]string) string {
 	return query
 }
 
+// IsDDL returns true if the first statement of the given query is a DDL,
+// headers before the statement are ignored
 func IsDDL(stmt string) bool {
-	stmtWithoutComment := RemoveComments(stmt)
-	return ddlPattern.MatchString(strings.TrimSpace(stmtWithoutComment)) || ddlCreatePattern.MatchString(strings.TrimSpace(stmtWithoutComment))
+	for _, s := range ParseStatements(stmt) {
+		if s.Kind != StatementEmpty && !s.IsHeader() {
+			return s.IsDDL()
+		}
+	}
+	return false
 }
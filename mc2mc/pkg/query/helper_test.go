@@ -195,6 +195,13 @@ select CONCAT_WS('; ', COLLECT_LIST(dates)) AS dates from presentation.main.impo
 		expectedQuery := `select CONCAT_WS('; ', COLLECT_LIST(dates)) AS dates from presentation.main.important_date`
 		assert.Equal(t, expectedQuery, query)
 	})
+	t.Run("works with query contains comment marker and escaped quote inside string", func(t *testing.T) {
+		q1 := `set odps.sql.allow.fullscan=true;
+select 'it\'s; -- not a comment' AS note, ` + "`col;--x`" + ` from presentation.main.important_date;`
+		headers, query := query.SeparateHeadersAndQuery(q1)
+		assert.Equal(t, "set odps.sql.allow.fullscan=true\n;", headers)
+		assert.Equal(t, `select 'it\'s; -- not a comment' AS note, `+"`col;--x`"+` from presentation.main.important_date`, query)
+	})
 	t.Run("works with query with comment on header", func(t *testing.T) {
 		q1 := `set odps.sql.allow.fullscan=true;
 -- comment here
@@ -406,6 +413,11 @@ SELECT * FROM project.dataset.table;
 
 `, query)
 	})
+	t.Run("returns query keeping comment markers inside strings", func(t *testing.T) {
+		q1 := `SELECT '--not comment', "/* nor this */" FROM t -- comment`
+		query := query.RemoveComments(q1)
+		assert.Equal(t, `SELECT '--not comment', "/* nor this */" FROM t `, query)
+	})
 	t.Run("returns query without comment and no changing query structure", func(t *testing.T) {
 		q1 := `SELECT * -- comment here
 FROM project.dataset.table;`
@@ -415,6 +427,20 @@ FROM project.dataset.table;`, query)
 	})
 }
 
+func TestIsDDL(t *testing.T) {
+	t.Run("returns true for ddl with leading comment", func(t *testing.T) {
+		assert.True(t, query.IsDDL("-- drop it;\nDROP TABLE IF EXISTS append_tmp"))
+		assert.True(t, query.IsDDL("/* create */ CREATE TABLE append_tmp (id bigint)"))
+	})
+	t.Run("returns true for ddl after headers", func(t *testing.T) {
+		assert.True(t, query.IsDDL("set odps.sql.allow.fullscan=true;\nALTER TABLE append_tmp ADD COLUMNS (age bigint);"))
+	})
+	t.Run("returns false for dml", func(t *testing.T) {
+		assert.False(t, query.IsDDL("INSERT INTO append_tmp SELECT '-- DROP TABLE x'"))
+		assert.False(t, query.IsDDL("CREATE TABLE append_tmp AS SELECT 1 id"))
+	})
+}
+
 func TestProtectedStringLiteral(t *testing.T) {
 	t.Run("returns query with protected string literals", func(t *testing.T) {
 		q1 := `SELECT * FROM project.dataset.table WHERE name = 'john' AND age = 20;`
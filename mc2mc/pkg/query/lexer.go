@@ -0,0 +1,216 @@
+package query
+
+import (
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// TokenType is the lexical category of a token in a MaxCompute SQL script
+type TokenType uint8
+
+const (
+	TokenWhitespace       TokenType = iota // spaces, tabs and newlines
+	TokenLineComment                       // -- comment until end of line
+	TokenBlockComment                      // /* comment */
+	TokenWord                              // keyword or unquoted identifier
+	TokenQuotedIdentifier                  // `identifier`
+	TokenString                            // 'string' or "string"
+	TokenNumber                            // numeric literal
+	TokenVariable                          // @variable
+	TokenSemicolon                         // statement terminator
+	TokenSymbol                            // operators and punctuations
+)
+
+// multiCharSymbols are operators which must be kept as a single token
+var multiCharSymbols = []string{"<=>", ":=", "<>", "!=", ">=", "<=", "==", "||"}
+
+// Token is a lexical unit of a script with its byte offsets in the source
+type Token struct {
+	Type  TokenType
+	Value string
+	Start int // inclusive byte offset in the source
+	End   int // exclusive byte offset in the source
+}
+
+// IsComment returns true if the token is a line or block comment
+func (t Token) IsComment() bool {
+	return t.Type == TokenLineComment || t.Type == TokenBlockComment
+}
+
+// IsTrivia returns true if the token doesn't affect the meaning of the script
+func (t Token) IsTrivia() bool {
+	return t.Type == TokenWhitespace || t.IsComment()
+}
+
+// IsKeyword returns true if the token is an unquoted word matching
+// the given keyword, case insensitively
+func (t Token) IsKeyword(keyword string) bool {
+	return t.Type == TokenWord && strings.EqualFold(t.Value, keyword)
+}
+
+// Segment is a single statement of a script, delimited by semicolon
+type Segment struct {
+	Text   string  // statement text without the terminating semicolon, trimmed
+	Start  int     // inclusive byte offset of the text in the source
+	End    int     // exclusive byte offset of the text in the source
+	Tokens []Token // tokens of the text, including comments and whitespaces
+}
+
+// IsEmpty returns true if the segment only contains comments
+func (s Segment) IsEmpty() bool {
+	for _, t := range s.Tokens {
+		if !t.IsTrivia() {
+			return false
+		}
+	}
+	return true
+}
+
+// Tokenize splits the given script into tokens. Quoted strings with escaped
+// quotes, backtick identifiers, line and block comments are recognized so
+// semicolons or comment markers inside them are not misinterpreted.
+// Unterminated strings and comments run until the end of the script.
+func Tokenize(query string) []Token {
+	tokens := []Token{}
+	for pos := 0; pos < len(query); {
+		typ, end := scanToken(query, pos)
+		tokens = append(tokens, Token{Type: typ, Value: query[pos:end], Start: pos, End: end})
+		pos = end
+	}
+	return tokens
+}
+
+// SplitStatements splits the given script into statements on every semicolon
+// outside of strings, identifiers and comments. Segments containing only
+// whitespaces are omitted, comment only segments are kept.
+func SplitStatements(query string) []Segment {
+	segments := []Segment{}
+	current := []Token{}
+	flush := func() {
+		// trim leading and trailing whitespaces
+		first, last := 0, len(current)-1
+		for first <= last && current[first].Type == TokenWhitespace {
+			first++
+		}
+		for last >= first && current[last].Type == TokenWhitespace {
+			last--
+		}
+		if first <= last {
+			start, end := current[first].Start, current[last].End
+			segments = append(segments, Segment{
+				Text:   query[start:end],
+				Start:  start,
+				End:    end,
+				Tokens: current[first : last+1],
+			})
+		}
+		current = []Token{}
+	}
+
+	for _, t := range Tokenize(query) {
+		if t.Type == TokenSemicolon {
+			flush()
+			continue
+		}
+		current = append(current, t)
+	}
+	flush()
+
+	return segments
+}
+
+// scanToken scans a single token starting at pos and returns its type and end offset
+func scanToken(query string, pos int) (TokenType, int) {
+	c := query[pos]
+	switch {
+	case c == ';':
+		return TokenSemicolon, pos + 1
+	case isSpace(c):
+		end := pos + 1
+		for end < len(query) && isSpace(query[end]) {
+			end++
+		}
+		return TokenWhitespace, end
+	case strings.HasPrefix(query[pos:], "--"):
+		end := strings.IndexByte(query[pos:], '\n')
+		if end < 0 {
+			return TokenLineComment, len(query)
+		}
+		return TokenLineComment, pos + end
+	case strings.HasPrefix(query[pos:], "/*"):
+		end := strings.Index(query[pos+2:], "*/")
+		if end < 0 {
+			return TokenBlockComment, len(query)
+		}
+		return TokenBlockComment, pos + 2 + end + 2
+	case c == '\'' || c == '"':
+		return TokenString, scanQuoted(query, pos, c, true)
+	case c == '`':
+		return TokenQuotedIdentifier, scanQuoted(query, pos, c, false)
+	case c == '@':
+		return TokenVariable, scanWord(query, pos+1)
+	case c >= '0' && c <= '9':
+		end := pos + 1
+		for end < len(query) && (isWordByte(query[end]) || query[end] == '.') {
+			end++
+		}
+		return TokenNumber, end
+	case isWordByte(c):
+		return TokenWord, scanWord(query, pos)
+	case c >= utf8.RuneSelf:
+		if end := scanWord(query, pos); end > pos {
+			return TokenWord, end
+		}
+		_, size := utf8.DecodeRuneInString(query[pos:])
+		return TokenSymbol, pos + size
+	}
+
+	for _, symbol := range multiCharSymbols {
+		if strings.HasPrefix(query[pos:], symbol) {
+			return TokenSymbol, pos + len(symbol)
+		}
+	}
+	return TokenSymbol, pos + 1
+}
+
+// scanQuoted returns the end offset of a quoted token starting at pos,
+// backslash escapes the next character when escapable is set
+func scanQuoted(query string, pos int, quote byte, escapable bool) int {
+	for i := pos + 1; i < len(query); i++ {
+		switch query[i] {
+		case '\\':
+			if escapable {
+				i++
+			}
+		case quote:
+			return i + 1
+		}
+	}
+	return len(query)
+}
+
+// scanWord returns the end offset of a word starting at pos
+func scanWord(query string, pos int) int {
+	end := pos
+	for end < len(query) {
+		if isWordByte(query[end]) {
+			end++
+			continue
+		}
+		r, size := utf8.DecodeRuneInString(query[end:])
+		if r == utf8.RuneError || !(unicode.IsLetter(r) || unicode.IsDigit(r)) {
+			break
+		}
+		end += size
+	}
+	return end
+}
+
+func isSpace(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\n' || c == '\r' || c == '\f' || c == '\v'
+}
+
+func isWordByte(c byte) bool {
+	return c == '_' || c == '$' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') || (c >= '0' && c <= '9')
+}
@@ -0,0 +1,94 @@
+package query_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/goto/transformers/mc2mc/pkg/query"
+)
+
+func TestTokenize(t *testing.T) {
+	t.Run("returns tokens with source offsets", func(t *testing.T) {
+		q1 := `select a, 'b' from t;`
+		tokens := query.Tokenize(q1)
+		for _, token := range tokens {
+			assert.Equal(t, q1[token.Start:token.End], token.Value)
+		}
+		types := []query.TokenType{}
+		for _, token := range tokens {
+			types = append(types, token.Type)
+		}
+		assert.Equal(t, []query.TokenType{
+			query.TokenWord, query.TokenWhitespace, query.TokenWord, query.TokenSymbol, query.TokenWhitespace,
+			query.TokenString, query.TokenWhitespace, query.TokenWord, query.TokenWhitespace, query.TokenWord,
+			query.TokenSemicolon,
+		}, types)
+	})
+	t.Run("recognizes escaped quote inside string", func(t *testing.T) {
+		tokens := query.Tokenize(`'it\'s; -- not a comment'`)
+		assert.Len(t, tokens, 1)
+		assert.Equal(t, query.TokenString, tokens[0].Type)
+	})
+	t.Run("recognizes double quoted string and backtick identifier", func(t *testing.T) {
+		tokens := query.Tokenize("\"a;b\" `c--d`")
+		assert.Len(t, tokens, 3)
+		assert.Equal(t, query.TokenString, tokens[0].Type)
+		assert.Equal(t, query.TokenQuotedIdentifier, tokens[2].Type)
+		assert.Equal(t, "`c--d`", tokens[2].Value)
+	})
+	t.Run("recognizes comments", func(t *testing.T) {
+		tokens := query.Tokenize("-- a; 'b\n/* c; */x")
+		assert.Len(t, tokens, 4)
+		assert.Equal(t, query.TokenLineComment, tokens[0].Type)
+		assert.Equal(t, "-- a; 'b", tokens[0].Value)
+		assert.Equal(t, query.TokenBlockComment, tokens[2].Type)
+		assert.Equal(t, "/* c; */", tokens[2].Value)
+		assert.Equal(t, query.TokenWord, tokens[3].Type)
+	})
+	t.Run("recognizes variables and assignment symbol", func(t *testing.T) {
+		tokens := query.Tokenize("@src := 1")
+		assert.Equal(t, query.TokenVariable, tokens[0].Type)
+		assert.Equal(t, "@src", tokens[0].Value)
+		assert.Equal(t, query.TokenSymbol, tokens[2].Type)
+		assert.Equal(t, ":=", tokens[2].Value)
+		assert.Equal(t, query.TokenNumber, tokens[4].Type)
+	})
+	t.Run("consumes unterminated string until the end", func(t *testing.T) {
+		tokens := query.Tokenize("select 'abc; def")
+		assert.Equal(t, query.TokenString, tokens[len(tokens)-1].Type)
+		assert.Equal(t, "'abc; def", tokens[len(tokens)-1].Value)
+	})
+}
+
+func TestSplitStatements(t *testing.T) {
+	t.Run("splits statements on semicolon", func(t *testing.T) {
+		q1 := "set a=1;\nselect 1; select 2\n"
+		segments := query.SplitStatements(q1)
+		assert.Len(t, segments, 3)
+		assert.Equal(t, "set a=1", segments[0].Text)
+		assert.Equal(t, "select 1", segments[1].Text)
+		assert.Equal(t, "select 2", segments[2].Text)
+		for _, segment := range segments {
+			assert.Equal(t, q1[segment.Start:segment.End], segment.Text)
+		}
+	})
+	t.Run("ignores semicolon inside strings, identifiers and comments", func(t *testing.T) {
+		q1 := "select 'a;b', \"c;d\", `e;f`, 'it\\'s; fine' -- g;\n/* h; */ from t;"
+		segments := query.SplitStatements(q1)
+		assert.Len(t, segments, 1)
+		assert.Equal(t, q1[:len(q1)-1], segments[0].Text)
+	})
+	t.Run("keeps comment only segments", func(t *testing.T) {
+		segments := query.SplitStatements("select 1;\n-- comment;\n")
+		assert.Len(t, segments, 2)
+		assert.False(t, segments[0].IsEmpty())
+		assert.Equal(t, "-- comment;", segments[1].Text)
+		assert.True(t, segments[1].IsEmpty())
+	})
+	t.Run("omits whitespace only segments", func(t *testing.T) {
+		segments := query.SplitStatements(";\n ; select 1;;")
+		assert.Len(t, segments, 1)
+		assert.Equal(t, "select 1", segments[0].Text)
+	})
+}
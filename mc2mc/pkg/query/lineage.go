@@ -0,0 +1,210 @@
+package query
+
+import (
+	"sort"
+	"strings"
+)
+
+// Lineage is the set of tables read and written by a query
+type Lineage struct {
+	Sources []string
+	Targets []string
+}
+
+// ExtractLineage returns the tables read and written by the given query.
+// Sources are collected from FROM, JOIN and MERGE USING clauses including subqueries,
+// CTE names and variables are not reported as tables. Targets are collected from
+// statements writing or changing a table, plus the destination table for the
+// load methods which write the query result into it.
+func ExtractLineage(query string, method Method, destinationTableID string) Lineage {
+	sources := map[string]bool{}
+	targets := map[string]bool{}
+	for _, stmt := range ParseStatements(query) {
+		lineage := stmt.Lineage()
+		for _, source := range lineage.Sources {
+			sources[source] = true
+		}
+		for _, target := range lineage.Targets {
+			targets[target] = true
+		}
+	}
+	if method != MERGE && destinationTableID != "" {
+		targets[NormalizeTableName(destinationTableID)] = true
+	}
+	return Lineage{
+		Sources: sortedKeys(sources),
+		Targets: sortedKeys(targets),
+	}
+}
+
+// Lineage returns the tables read and written by the statement
+func (s Statement) Lineage() Lineage {
+	tokens := significantTokens(s.Tokens)
+	targets := map[string]bool{}
+	switch s.Kind {
+	case StatementCreateTable, StatementCreateTableAs, StatementCreateView,
+		StatementMerge, StatementDelete, StatementUpdate, StatementAlter, StatementTruncate:
+		if s.Target != "" {
+			targets[NormalizeTableName(s.Target)] = true
+		}
+	case StatementDrop:
+		if s.Target != "" {
+			targets[NormalizeTableName(s.Target)] = true
+		}
+		// dropping a table doesn't read anything
+		return Lineage{Sources: []string{}, Targets: sortedKeys(targets)}
+	case StatementInsert:
+		// multi insert statement writes to every INSERT target
+		for i := indexTopLevelKeyword(tokens, 0, "INSERT"); i >= 0; i = indexTopLevelKeyword(tokens, i+1, "INSERT") {
+			if target := parseInsertTarget(tokens, i); target != "" {
+				targets[NormalizeTableName(target)] = true
+			}
+		}
+	}
+
+	ctes := collectCTENames(tokens)
+	sources := map[string]bool{}
+	for _, source := range collectSourceTables(tokens, s.Kind) {
+		if ctes[strings.ToLower(source)] {
+			continue
+		}
+		sources[source] = true
+	}
+	return Lineage{
+		Sources: sortedKeys(sources),
+		Targets: sortedKeys(targets),
+	}
+}
+
+// NormalizeTableName removes backticks and surrounding spaces from the table name
+func NormalizeTableName(name string) string {
+	return strings.ReplaceAll(strings.TrimSpace(name), "`", "")
+}
+
+// collectCTENames returns the lower cased names defined in WITH clauses
+func collectCTENames(tokens []Token) map[string]bool {
+	names := map[string]bool{}
+	for i, t := range tokens {
+		if !t.IsKeyword("WITH") {
+			continue
+		}
+		// WITH name AS (...), name AS (...)
+		for j := i + 1; j+1 < len(tokens); {
+			if tokens[j].Type != TokenWord && tokens[j].Type != TokenQuotedIdentifier {
+				break
+			}
+			if !tokens[j+1].IsKeyword("AS") {
+				break
+			}
+			names[strings.ToLower(NormalizeTableName(tokens[j].Value))] = true
+			j = skipParentheses(tokens, j+2)
+			if j >= len(tokens) || tokens[j].Value != "," {
+				break
+			}
+			j++
+		}
+	}
+	return names
+}
+
+// collectSourceTables returns table names following FROM, JOIN and USING keywords,
+// FROM inside function calls, such as EXTRACT(YEAR FROM col), is ignored
+func collectSourceTables(tokens []Token, kind StatementKind) []string {
+	sources := []string{}
+	// stack of open parentheses, true when the parentheses enclose a query
+	queryParens := []bool{}
+	for i := 0; i < len(tokens); i++ {
+		t := tokens[i]
+		switch {
+		case t.Value == "(":
+			isQuery := i+1 < len(tokens) && (tokens[i+1].IsKeyword("SELECT") || tokens[i+1].IsKeyword("WITH") ||
+				tokens[i+1].IsKeyword("FROM") || tokens[i+1].Value == "(")
+			queryParens = append(queryParens, isQuery)
+			continue
+		case t.Value == ")":
+			if len(queryParens) > 0 {
+				queryParens = queryParens[:len(queryParens)-1]
+			}
+			continue
+		}
+		if len(queryParens) > 0 && !queryParens[len(queryParens)-1] {
+			continue
+		}
+
+		isSource := t.IsKeyword("JOIN") || t.IsKeyword("FROM") ||
+			(kind == StatementMerge && t.IsKeyword("USING"))
+		if !isSource || (kind == StatementDelete && i == 1 && t.IsKeyword("FROM")) {
+			continue
+		}
+		// FROM a alias, b alias JOIN ...
+		for j := i + 1; j < len(tokens); {
+			name, next := readTableName(tokens, j)
+			if name == "" || isReservedWord(name) {
+				break
+			}
+			sources = append(sources, NormalizeTableName(name))
+			next = skipAlias(tokens, next)
+			if next >= len(tokens) || tokens[next].Value != "," || !t.IsKeyword("FROM") {
+				break
+			}
+			j = next + 1
+		}
+	}
+	return sources
+}
+
+// skipParentheses returns the index after the balanced parentheses starting at index i
+func skipParentheses(tokens []Token, i int) int {
+	if i >= len(tokens) || tokens[i].Value != "(" {
+		return i
+	}
+	depth := 0
+	for ; i < len(tokens); i++ {
+		switch tokens[i].Value {
+		case "(":
+			depth++
+		case ")":
+			depth--
+			if depth == 0 {
+				return i + 1
+			}
+		}
+	}
+	return i
+}
+
+// skipAlias returns the index after an optional table alias at index i
+func skipAlias(tokens []Token, i int) int {
+	if i < len(tokens) && tokens[i].IsKeyword("AS") {
+		i++
+	}
+	if i < len(tokens) && (tokens[i].Type == TokenQuotedIdentifier ||
+		(tokens[i].Type == TokenWord && !isReservedWord(tokens[i].Value))) {
+		i++
+	}
+	return i
+}
+
+// sourceBoundaryWords are keywords which can follow a table name in FROM clause
+var sourceBoundaryWords = map[string]bool{
+	"select": true, "where": true, "group": true, "order": true, "having": true, "limit": true,
+	"join": true, "left": true, "right": true, "full": true, "inner": true, "outer": true,
+	"cross": true, "semi": true, "anti": true, "on": true, "using": true, "union": true,
+	"intersect": true, "except": true, "minus": true, "lateral": true, "insert": true,
+	"when": true, "window": true, "distribute": true, "sort": true, "cluster": true,
+	"partition": true, "values": true, "set": true, "natural": true, "with": true, "as": true,
+}
+
+// isReservedWord returns true if the word can't be a table name or alias
+func isReservedWord(word string) bool {
+	return sourceBoundaryWords[strings.ToLower(word)]
+}
+
+func sortedKeys(m map[string]bool) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
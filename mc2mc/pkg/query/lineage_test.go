@@ -0,0 +1,72 @@
+package query_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/goto/transformers/mc2mc/pkg/query"
+)
+
+func TestExtractLineage(t *testing.T) {
+	t.Run("returns sources and destination for append load method", func(t *testing.T) {
+		q1 := `set odps.sql.allow.fullscan=true;
+WITH cte AS (
+  SELECT id, EXTRACT(YEAR FROM created_at) AS y FROM project.playground.orders
+)
+SELECT c.id, u.name
+FROM cte c
+JOIN ` + "`project`.`playground`.`users`" + ` u ON c.id = u.id
+LEFT JOIN (SELECT id FROM project.playground.payments WHERE status = 'FROM x') p ON p.id = c.id
+WHERE c.id IN (SELECT id FROM project.playground.allowed), project.playground.ignored_as_expression`
+		lineage := query.ExtractLineage(q1, query.APPEND, "project.playground.destination")
+		assert.Equal(t, []string{
+			"project.playground.allowed",
+			"project.playground.orders",
+			"project.playground.payments",
+			"project.playground.users",
+		}, lineage.Sources)
+		assert.Equal(t, []string{"project.playground.destination"}, lineage.Targets)
+	})
+	t.Run("returns sources and targets for merge load method script", func(t *testing.T) {
+		q1 := `DROP TABLE IF EXISTS project.playground.tmp;
+@src := SELECT a.id FROM project.playground.a a, project.playground.b b WHERE a.id = b.id;
+CREATE TABLE project.playground.tmp AS SELECT * FROM @src;
+MERGE INTO project.playground.target t
+USING project.playground.tmp s
+ON t.id = s.id
+WHEN MATCHED THEN UPDATE SET t.id = s.id;
+FROM project.playground.c
+INSERT OVERWRITE TABLE project.playground.d SELECT id
+INSERT INTO TABLE project.playground.e SELECT id;
+DELETE FROM project.playground.f WHERE id IN (SELECT id FROM project.playground.g);`
+		lineage := query.ExtractLineage(q1, query.MERGE, "project.playground.ignored")
+		assert.Equal(t, []string{
+			"project.playground.a",
+			"project.playground.b",
+			"project.playground.c",
+			"project.playground.g",
+			"project.playground.tmp",
+		}, lineage.Sources)
+		assert.Equal(t, []string{
+			"project.playground.d",
+			"project.playground.e",
+			"project.playground.f",
+			"project.playground.target",
+			"project.playground.tmp",
+		}, lineage.Targets)
+	})
+}
+
+func TestParseMethod(t *testing.T) {
+	t.Run("returns method for known name", func(t *testing.T) {
+		method, err := query.ParseMethod("replace")
+		assert.NoError(t, err)
+		assert.Equal(t, query.REPLACE, method)
+		assert.Equal(t, "REPLACE", method.String())
+	})
+	t.Run("returns error for unknown name", func(t *testing.T) {
+		_, err := query.ParseMethod("UNKNOWN")
+		assert.Error(t, err)
+	})
+}
@@ -1,9 +1,41 @@
 package query
 
+import (
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
 type Method uint8
 
 const (
 	MERGE Method = iota
 	APPEND
 	REPLACE
+	UPSERT
+	DELETE_INSERT
+	SCD2
 )
+
+var methodNames = map[Method]string{
+	MERGE:         "MERGE",
+	APPEND:        "APPEND",
+	REPLACE:       "REPLACE",
+	UPSERT:        "UPSERT",
+	DELETE_INSERT: "DELETE_INSERT",
+	SCD2:          "SCD2",
+}
+
+func (m Method) String() string {
+	return methodNames[m]
+}
+
+// ParseMethod returns the method of the given load method name
+func ParseMethod(name string) (Method, error) {
+	for method, methodName := range methodNames {
+		if strings.EqualFold(name, methodName) {
+			return method, nil
+		}
+	}
+	return MERGE, errors.Errorf("not supported load method: %s", name)
+}
@@ -47,6 +47,24 @@ func WithOverridedValue(field, value string) Option {
 	}
 }
 
+func WithStaticPartition(column, value string) Option {
+	return func(b *Builder) {
+		if b.staticPartitions == nil {
+			b.staticPartitions = make(map[string]string)
+		}
+		b.staticPartitions[column] = value
+	}
+}
+
+func WithPartitionExpression(column, expression string) Option {
+	return func(b *Builder) {
+		if b.partitionExpressions == nil {
+			b.partitionExpressions = make(map[string]string)
+		}
+		b.partitionExpressions[column] = expression
+	}
+}
+
 func WithCostAttributionLabel(teamName string) Option {
 	return func(b *Builder) {
 		b.costAttributionTeam = teamName
@@ -58,3 +76,61 @@ func WithDryRun(enable bool) Option {
 		b.enableDryRun = enable
 	}
 }
+
+func WithMergeKeys(keys ...string) Option {
+	return func(b *Builder) {
+		b.mergeKeys = keys
+	}
+}
+
+func WithDeletePredicate(predicate string) Option {
+	return func(b *Builder) {
+		b.deletePredicate = predicate
+	}
+}
+
+func WithBusinessKeys(keys ...string) Option {
+	return func(b *Builder) {
+		b.businessKeys = keys
+	}
+}
+
+func WithTrackedColumns(columns ...string) Option {
+	return func(b *Builder) {
+		b.trackedColumns = columns
+	}
+}
+
+func WithValidityColumns(validFrom, validTo, isCurrent string) Option {
+	return func(b *Builder) {
+		b.validFromColumn = validFrom
+		b.validToColumn = validTo
+		b.isCurrentColumn = isCurrent
+	}
+}
+
+func WithColumnMapping(enable, fillMissing bool) Option {
+	return func(b *Builder) {
+		b.enableColumnMapping = enable
+		b.fillMissingColumns = fillMissing
+	}
+}
+
+func WithQueryColumns(columns ...Column) Option {
+	return func(b *Builder) {
+		b.queryColumns = columns
+	}
+}
+
+func WithFieldAddition(enable bool) Option {
+	return func(b *Builder) {
+		b.enableFieldAddition = enable
+	}
+}
+
+func WithTypeCasting(enable, strict bool) Option {
+	return func(b *Builder) {
+		b.enableTypeCasting = enable
+		b.strictTypeCasting = strict
+	}
+}
@@ -0,0 +1,161 @@
+package query
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// constructPartitionSpec constructs the partition spec of the insert query. Partition columns
+// are dynamic by default, columns with static value are written as name='value' so the query
+// can only write to the intended partition, e.g. dt='2024-01-01', hh. Static columns must be
+// the higher levels since ODPS doesn't allow static partition after dynamic one.
+func (b *Builder) constructPartitionSpec(partitionNames []string) (string, error) {
+	for name := range b.staticPartitions {
+		if _, ok := findColumn(partitionNames, name); !ok {
+			return "", errors.Errorf("partition column %s is not found in destination table %s", name, b.destinationTableID)
+		}
+	}
+	for name := range b.partitionExpressions {
+		if _, ok := findColumn(partitionNames, name); !ok {
+			return "", errors.Errorf("partition column %s is not found in destination table %s", name, b.destinationTableID)
+		}
+	}
+
+	specs := make([]string, len(partitionNames))
+	dynamicPartition := ""
+	for i, partitionName := range partitionNames {
+		value, ok := b.staticPartitionValue(partitionName)
+		if !ok {
+			specs[i] = partitionName
+			if dynamicPartition == "" {
+				dynamicPartition = partitionName
+			}
+			continue
+		}
+		if dynamicPartition != "" {
+			return "", errors.Errorf("static partition column %s can't follow dynamic partition column %s", partitionName, dynamicPartition)
+		}
+		specs[i] = fmt.Sprintf("%s=%s", partitionName, quoteString(value))
+	}
+	return strings.Join(specs, ", "), nil
+}
+
+// constructPartitionExpressions returns the projection of the dynamic partition columns
+// computed by expressions along with their names, they're selected last in the order
+// of the partition columns. It returns nothing on auto partition.
+func (b *Builder) constructPartitionExpressions() ([]string, []string, error) {
+	if len(b.partitionExpressions) == 0 || b.enableAutoPartition {
+		return nil, nil, nil
+	}
+	partitionNames, err := b.client.GetPartitionNames(context.Background(), b.destinationTableID)
+	if err != nil {
+		return nil, nil, errors.WithStack(err)
+	}
+	columns := []string{}
+	names := []string{}
+	for _, partitionName := range partitionNames {
+		for name, expression := range b.partitionExpressions {
+			if _, ok := findColumn([]string{name}, partitionName); ok {
+				columns = append(columns, fmt.Sprintf("%s as %s", expression, partitionName))
+				names = append(names, partitionName)
+			}
+		}
+	}
+	return columns, names, nil
+}
+
+// constructPartitionProjection constructs query projecting the destination columns
+// followed by the given partition columns
+func (b *Builder) constructPartitionProjection(query string, partitionColumns []string) (string, error) {
+	if err := b.fetchOrderedColumns(); err != nil {
+		return "", errors.WithStack(err)
+	}
+	columns := append(append([]string{}, b.projectedColumns()...), partitionColumns...)
+	return fmt.Sprintf("SELECT %s FROM (\n%s\n)", strings.Join(columns, ", "), query), nil
+}
+
+// staticPartitionValue returns the static value of the given partition column if any
+func (b *Builder) staticPartitionValue(partitionName string) (string, bool) {
+	for name, value := range b.staticPartitions {
+		if _, ok := findColumn([]string{name}, partitionName); ok {
+			return value, true
+		}
+	}
+	return "", false
+}
+
+// projectedColumns returns the ordered columns without the static partition columns
+// since their values are given by the partition spec
+func (b *Builder) projectedColumns() []string {
+	if len(b.staticPartitions) == 0 {
+		return b.orderedColumns
+	}
+	columns := make([]string, 0, len(b.orderedColumns))
+	for _, column := range b.orderedColumns {
+		if _, ok := b.staticPartitionValue(column); !ok {
+			columns = append(columns, column)
+		}
+	}
+	return columns
+}
+
+// quoteString returns single quoted string literal of the given value
+func quoteString(value string) string {
+	value = strings.ReplaceAll(value, `\`, `\\`)
+	return fmt.Sprintf("'%s'", strings.ReplaceAll(value, "'", `\'`))
+}
+
+// PartitionValueOptions returns the builder options of partition values configured per partition column,
+// the values are evaluated against the given window time. The supported values are:
+//   - date or date:<layout>, static value of the window date, default layout is 2006-01-02
+//   - hour or hour:<layout>, static value of the window hour, default layout is 15
+//   - time:<layout>, static value of the window time with the given go layout
+//   - expr:<expression>, dynamic value computed by the given sql expression
+//   - dynamic, dynamic value taken from the query result
+//   - any other value is used as static constant
+func PartitionValueOptions(values map[string]string, t time.Time) []Option {
+	options := []Option{}
+	for column, value := range StaticPartitionValues(values, t) {
+		options = append(options, WithStaticPartition(column, value))
+	}
+	for column, value := range values {
+		kind, arg, _ := strings.Cut(strings.TrimSpace(value), ":")
+		if strings.EqualFold(kind, "expr") {
+			options = append(options, WithPartitionExpression(column, arg))
+		}
+	}
+	return options
+}
+
+// StaticPartitionValues returns the static values of the partition values configured per partition column
+// evaluated against the given window time, dynamic ones are not included
+func StaticPartitionValues(values map[string]string, t time.Time) map[string]string {
+	staticValues := map[string]string{}
+	for column, value := range values {
+		kind, arg, _ := strings.Cut(strings.TrimSpace(value), ":")
+		switch strings.ToLower(kind) {
+		case "date":
+			staticValues[column] = t.Format(defaultString(arg, "2006-01-02"))
+		case "hour":
+			staticValues[column] = t.Format(defaultString(arg, "15"))
+		case "time":
+			staticValues[column] = t.Format(defaultString(arg, time.DateTime))
+		case "expr", "dynamic":
+		default:
+			staticValues[column] = value
+		}
+	}
+	return staticValues
+}
+
+// defaultString returns the value or the default one if it's empty
+func defaultString(value, defaultValue string) string {
+	if value == "" {
+		return defaultValue
+	}
+	return value
+}
@@ -0,0 +1,51 @@
+package query_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/goto/transformers/mc2mc/internal/logger"
+	"github.com/goto/transformers/mc2mc/pkg/query"
+)
+
+func TestPartitionValueOptions(t *testing.T) {
+	t.Run("returns partition spec evaluated against window time", func(t *testing.T) {
+		odspClient := &mockOdpsClient{
+			orderedColumns: func() ([]string, error) {
+				return []string{"id", "name"}, nil
+			},
+			partitionResult: func() ([]string, error) {
+				return []string{"dt", "hh", "source", "region"}, nil
+			},
+		}
+		window := time.Date(2024, 1, 2, 15, 0, 0, 0, time.UTC)
+		options := query.PartitionValueOptions(map[string]string{
+			"dt":     "date:20060102",
+			"hh":     "hour",
+			"source": "app",
+			"region": "expr:COALESCE(region, 'unknown')",
+		}, window)
+
+		queryToExecute, err := query.NewBuilder(
+			logger.NewDefaultLogger(),
+			odspClient,
+			append(options,
+				query.WithQuery(`select id, name, region from project.playground.table`),
+				query.WithMethod(query.REPLACE),
+				query.WithDestination("project.playground.table_destination"),
+			)...,
+		).Build()
+		assert.NoError(t, err)
+		assert.Equal(t, `INSERT OVERWRITE TABLE project.playground.table_destination PARTITION (dt='20240102', hh='15', source='app', region) 
+SELECT id, name, COALESCE(region, 'unknown') as region FROM (
+select id, name, region from project.playground.table
+)
+;`, queryToExecute)
+	})
+	t.Run("returns no option for dynamic partition", func(t *testing.T) {
+		options := query.PartitionValueOptions(map[string]string{"dt": "dynamic"}, time.Now())
+		assert.Empty(t, options)
+	})
+}
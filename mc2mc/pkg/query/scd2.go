@@ -0,0 +1,154 @@
+package query
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// constructSCD2Query constructs slowly changing dimension type 2 statements:
+// the close-out statement expires the current rows whose tracked columns changed,
+// then the insert statement adds new versions for changed and new business keys.
+// Both statements are separated by break marker as they must be executed in order,
+// the destination must be a transactional table to support MERGE INTO.
+func (b *Builder) constructSCD2Query(query string) (string, error) {
+	if len(b.businessKeys) == 0 {
+		return "", errors.New("business keys are required for scd2 method")
+	}
+	if b.validFromColumn == "" || b.validToColumn == "" || b.isCurrentColumn == "" {
+		return "", errors.New("valid from, valid to and is current columns are required for scd2 method")
+	}
+
+	if err := b.fetchOrderedColumns(); err != nil {
+		return "", errors.WithStack(err)
+	}
+	partitionNames, err := b.client.GetPartitionNames(context.Background(), b.destinationTableID)
+	if err != nil {
+		return "", errors.WithStack(err)
+	}
+	columns := append([]string{}, b.orderedColumns...)
+	for _, partitionName := range partitionNames {
+		if _, ok := findColumn(columns, partitionName); !ok {
+			columns = append(columns, partitionName)
+		}
+	}
+
+	// validate the given columns against destination schema
+	lookup := func(names []string) ([]string, error) {
+		found := make([]string, len(names))
+		for i, name := range names {
+			column, ok := findColumn(columns, name)
+			if !ok {
+				return nil, errors.Errorf("column %s is not found in destination table %s", name, b.destinationTableID)
+			}
+			found[i] = column
+		}
+		return found, nil
+	}
+	keys, err := lookup(b.businessKeys)
+	if err != nil {
+		return "", errors.WithStack(err)
+	}
+	validityColumns, err := lookup([]string{b.validFromColumn, b.validToColumn, b.isCurrentColumn})
+	if err != nil {
+		return "", errors.WithStack(err)
+	}
+	validFrom, validTo, isCurrent := validityColumns[0], validityColumns[1], validityColumns[2]
+
+	// source columns are all destination columns except the validity ones
+	sourceColumns := []string{}
+	for _, column := range columns {
+		if _, ok := findColumn(validityColumns, column); !ok {
+			sourceColumns = append(sourceColumns, column)
+		}
+	}
+
+	// track all non key columns when tracked columns are not specified
+	tracked, err := lookup(b.trackedColumns)
+	if err != nil {
+		return "", errors.WithStack(err)
+	}
+	if len(tracked) == 0 {
+		for _, column := range sourceColumns {
+			_, isKey := findColumn(keys, column)
+			_, isPartition := findColumn(partitionNames, column)
+			if !isKey && !isPartition {
+				tracked = append(tracked, column)
+			}
+		}
+	}
+	if len(tracked) == 0 {
+		return "", errors.Errorf("no tracked columns for scd2 method in destination table %s", b.destinationTableID)
+	}
+
+	// the new versions are valid from the overrided value if any, otherwise from the execution time
+	effectiveTime := "CURRENT_TIMESTAMP()"
+	if val, ok := b.overridedValues[validFrom]; ok {
+		effectiveTime = val
+	}
+
+	projections := make([]string, len(sourceColumns))
+	for i, column := range sourceColumns {
+		projections[i] = column
+		if val, ok := b.overridedValues[column]; ok {
+			projections[i] = fmt.Sprintf("%s as %s", val, column)
+		}
+	}
+	source := fmt.Sprintf("SELECT %s FROM (\n%s\n)", strings.Join(projections, ", "), query)
+
+	conditions := make([]string, len(keys))
+	for i, key := range keys {
+		conditions[i] = fmt.Sprintf("t.%s = s.%s", key, key)
+	}
+	changes := make([]string, len(tracked))
+	for i, column := range tracked {
+		changes[i] = fmt.Sprintf("NOT (t.%s <=> s.%s)", column, column)
+	}
+
+	closeOut := strings.Builder{}
+	closeOut.WriteString(fmt.Sprintf("MERGE INTO %s AS t\nUSING (\n%s\n) AS s\n", b.destinationTableID, source))
+	closeOut.WriteString(fmt.Sprintf("ON %s AND t.%s = TRUE\n", strings.Join(conditions, " AND "), isCurrent))
+	closeOut.WriteString(fmt.Sprintf("WHEN MATCHED AND (%s) THEN UPDATE SET t.%s = %s, t.%s = FALSE\n;",
+		strings.Join(changes, " OR "), validTo, effectiveTime, isCurrent))
+
+	// valid to column is omitted from the insert so it's filled with NULL,
+	// dynamic partition columns are not listed but selected last in partition order
+	partition := ""
+	if len(partitionNames) > 0 && !b.enableAutoPartition {
+		partition = fmt.Sprintf("PARTITION (%s) ", strings.Join(partitionNames, ", "))
+	}
+	insertColumns := []string{}
+	values := []string{}
+	for _, column := range columns {
+		if _, isPartition := findColumn(partitionNames, column); isPartition && partition != "" {
+			continue
+		}
+		switch column {
+		case validFrom:
+			insertColumns = append(insertColumns, column)
+			values = append(values, fmt.Sprintf("%s as %s", effectiveTime, column))
+		case isCurrent:
+			insertColumns = append(insertColumns, column)
+			values = append(values, fmt.Sprintf("TRUE as %s", column))
+		case validTo:
+		default:
+			insertColumns = append(insertColumns, column)
+			values = append(values, fmt.Sprintf("s.%s", column))
+		}
+	}
+	if partition != "" {
+		for _, partitionName := range partitionNames {
+			values = append(values, fmt.Sprintf("s.%s", partitionName))
+		}
+	}
+
+	insert := strings.Builder{}
+	insert.WriteString(fmt.Sprintf("INSERT INTO TABLE %s %s(%s)\n", b.destinationTableID, partition, strings.Join(insertColumns, ", ")))
+	insert.WriteString(fmt.Sprintf("SELECT %s FROM (\n%s\n) AS s\n", strings.Join(values, ", "), source))
+	insert.WriteString(fmt.Sprintf("LEFT ANTI JOIN (SELECT * FROM %s WHERE %s = TRUE) AS t ON %s\n;",
+		b.destinationTableID, isCurrent, strings.Join(conditions, " AND ")))
+
+	return fmt.Sprintf("%s\n%s\n%s", closeOut.String(), BREAK_MARKER, insert.String()), nil
+}
@@ -0,0 +1,64 @@
+package query
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// ConstructAddColumnsQuery constructs ALTER TABLE query adding the query columns which don't exist
+// in the destination table yet without building the insert query, it's empty if there's no new column.
+func ConstructAddColumnsQuery(l *slog.Logger, client OdpsClient, destinationTableID string, queryColumns []Column) (string, error) {
+	b := NewBuilder(l, client,
+		WithDestination(destinationTableID),
+		WithColumnOrder(),
+		WithQueryColumns(queryColumns...),
+		WithFieldAddition(true),
+	)
+	addColumnsQuery, err := b.constructAddColumnsQuery()
+	return addColumnsQuery, errors.WithStack(err)
+}
+
+// constructAddColumnsQuery constructs ALTER TABLE query adding the query columns
+// which don't exist in the destination table yet. The added columns are included
+// in the ordered columns so they're projected by the insert query, except on dry run
+// where the destination is not altered.
+func (b *Builder) constructAddColumnsQuery() (string, error) {
+	// field addition only applies to the ordered projection
+	if !b.enableFieldAddition || len(b.queryColumns) == 0 || b.orderedColumns == nil {
+		return "", nil
+	}
+
+	if err := b.fetchOrderedColumns(); err != nil {
+		return "", errors.WithStack(err)
+	}
+	partitionNames, err := b.client.GetPartitionNames(context.Background(), b.destinationTableID)
+	if err != nil {
+		return "", errors.WithStack(err)
+	}
+
+	addedColumns := []string{}
+	definitions := []string{}
+	for _, column := range b.queryColumns {
+		if _, ok := findColumn(b.orderedColumns, column.Name); ok {
+			continue
+		}
+		if _, ok := findColumn(partitionNames, column.Name); ok {
+			continue
+		}
+		addedColumns = append(addedColumns, column.Name)
+		definitions = append(definitions, fmt.Sprintf("%s %s", column.Name, column.Type))
+	}
+	if len(addedColumns) == 0 {
+		return "", nil
+	}
+
+	b.l.Info(fmt.Sprintf("adding columns %s to destination table %s", strings.Join(addedColumns, ", "), b.destinationTableID))
+	if !b.enableDryRun {
+		b.orderedColumns = append(b.orderedColumns, addedColumns...)
+	}
+	return fmt.Sprintf("ALTER TABLE %s ADD COLUMNS IF NOT EXISTS (%s)\n;", b.destinationTableID, strings.Join(definitions, ", ")), nil
+}
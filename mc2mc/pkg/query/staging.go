@@ -0,0 +1,26 @@
+package query
+
+import (
+	"fmt"
+	"strings"
+)
+
+// ConstructStagingTableQuery constructs CREATE TABLE LIKE query for the staging table
+// of the destination, the table only lives for a day in case it's not dropped.
+func ConstructStagingTableQuery(stagingTableID, destinationTableID string) string {
+	return fmt.Sprintf("CREATE TABLE IF NOT EXISTS %s LIKE %s LIFECYCLE 1\n;", stagingTableID, destinationTableID)
+}
+
+// ConstructSwapQuery constructs INSERT OVERWRITE query moving the staging data into the destination,
+// partitioned destination only gets the partitions written to the staging table replaced.
+func ConstructSwapQuery(destinationTableID, stagingTableID string, partitionNames []string) string {
+	if len(partitionNames) == 0 {
+		return fmt.Sprintf("INSERT OVERWRITE TABLE %s \nSELECT * FROM %s\n;", destinationTableID, stagingTableID)
+	}
+	return fmt.Sprintf("INSERT OVERWRITE TABLE %s PARTITION (%s) \nSELECT * FROM %s\n;", destinationTableID, strings.Join(partitionNames, ", "), stagingTableID)
+}
+
+// ConstructDropTableQuery constructs DROP TABLE query of the given table
+func ConstructDropTableQuery(tableID string) string {
+	return fmt.Sprintf("DROP TABLE IF EXISTS %s\n;", tableID)
+}
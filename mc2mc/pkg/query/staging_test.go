@@ -0,0 +1,27 @@
+package query_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/goto/transformers/mc2mc/pkg/query"
+)
+
+func TestConstructStagingTableQuery(t *testing.T) {
+	t.Run("returns create table like the destination", func(t *testing.T) {
+		actual := query.ConstructStagingTableQuery("project.playground.table_staging", "project.playground.table")
+		assert.Equal(t, "CREATE TABLE IF NOT EXISTS project.playground.table_staging LIKE project.playground.table LIFECYCLE 1\n;", actual)
+	})
+}
+
+func TestConstructSwapQuery(t *testing.T) {
+	t.Run("returns insert overwrite of the whole table when it's not partitioned", func(t *testing.T) {
+		actual := query.ConstructSwapQuery("project.playground.table", "project.playground.table_staging", nil)
+		assert.Equal(t, "INSERT OVERWRITE TABLE project.playground.table \nSELECT * FROM project.playground.table_staging\n;", actual)
+	})
+	t.Run("returns insert overwrite of the staging partitions", func(t *testing.T) {
+		actual := query.ConstructSwapQuery("project.playground.table", "project.playground.table_staging", []string{"dt", "hh"})
+		assert.Equal(t, "INSERT OVERWRITE TABLE project.playground.table PARTITION (dt, hh) \nSELECT * FROM project.playground.table_staging\n;", actual)
+	})
+}
@@ -0,0 +1,246 @@
+package query
+
+import (
+	"strings"
+)
+
+// StatementKind is the classification of a statement in a script
+type StatementKind uint8
+
+const (
+	StatementUnknown       StatementKind = iota
+	StatementEmpty                       // comment only statement
+	StatementSet                         // SET odps.property=value
+	StatementVariable                    // @variable := expression
+	StatementFunction                    // FUNCTION name(@param type) AS expression
+	StatementCreateTable                 // CREATE TABLE name (columns) or CREATE TABLE name LIKE other
+	StatementCreateTableAs               // CREATE TABLE name AS SELECT ...
+	StatementCreateView                  // CREATE [MATERIALIZED] VIEW name AS SELECT ...
+	StatementDrop                        // DROP TABLE|VIEW|FUNCTION ...
+	StatementInsert                      // INSERT INTO|OVERWRITE [TABLE] name ...
+	StatementMerge                       // MERGE INTO name USING ...
+	StatementDelete                      // DELETE FROM name ...
+	StatementUpdate                      // UPDATE name SET ...
+	StatementSelect                      // SELECT ... or WITH ... SELECT ...
+	StatementAlter                       // ALTER TABLE name ...
+	StatementTruncate                    // TRUNCATE TABLE name
+)
+
+var statementKindNames = map[StatementKind]string{
+	StatementUnknown:       "UNKNOWN",
+	StatementEmpty:         "EMPTY",
+	StatementSet:           "SET",
+	StatementVariable:      "VARIABLE",
+	StatementFunction:      "FUNCTION",
+	StatementCreateTable:   "CREATE_TABLE",
+	StatementCreateTableAs: "CREATE_TABLE_AS",
+	StatementCreateView:    "CREATE_VIEW",
+	StatementDrop:          "DROP",
+	StatementInsert:        "INSERT",
+	StatementMerge:         "MERGE",
+	StatementDelete:        "DELETE",
+	StatementUpdate:        "UPDATE",
+	StatementSelect:        "SELECT",
+	StatementAlter:         "ALTER",
+	StatementTruncate:      "TRUNCATE",
+}
+
+func (k StatementKind) String() string {
+	if name, ok := statementKindNames[k]; ok {
+		return name
+	}
+	return statementKindNames[StatementUnknown]
+}
+
+// Statement is a classified statement of a script
+type Statement struct {
+	Kind StatementKind
+	// Target is the table written or affected by the statement,
+	// the variable name for variable assignment and the function name for UDF.
+	// It's empty when the statement has no target.
+	Target string
+	Segment
+}
+
+// IsHeader returns true if the statement is a session setting
+func (s Statement) IsHeader() bool {
+	return s.Kind == StatementSet
+}
+
+// IsDeclaration returns true if the statement declares a variable or UDF
+// which must be carried to every following statement of the script
+func (s Statement) IsDeclaration() bool {
+	return s.Kind == StatementVariable || s.Kind == StatementFunction
+}
+
+// IsDDL returns true if the statement changes table definitions only
+func (s Statement) IsDDL() bool {
+	switch s.Kind {
+	case StatementCreateTable, StatementDrop, StatementAlter, StatementTruncate:
+		return true
+	}
+	return false
+}
+
+// ParseStatements splits the given script and classifies each statement
+func ParseStatements(query string) []Statement {
+	segments := SplitStatements(query)
+	stmts := make([]Statement, len(segments))
+	for i, segment := range segments {
+		stmts[i] = parseStatement(segment)
+	}
+	return stmts
+}
+
+// parseStatement classifies a single statement based on its leading keywords
+func parseStatement(segment Segment) Statement {
+	stmt := Statement{Kind: StatementUnknown, Segment: segment}
+	tokens := significantTokens(segment.Tokens)
+	if len(tokens) == 0 {
+		stmt.Kind = StatementEmpty
+		return stmt
+	}
+
+	first := tokens[0]
+	switch {
+	case first.Type == TokenVariable:
+		stmt.Kind = StatementVariable
+		stmt.Target = first.Value
+	case first.IsKeyword("SET"):
+		stmt.Kind = StatementSet
+	case first.IsKeyword("FUNCTION"):
+		stmt.Kind = StatementFunction
+		stmt.Target, _ = readTableName(tokens, 1)
+	case first.IsKeyword("CREATE"):
+		stmt.Kind, stmt.Target = parseCreate(tokens)
+	case first.IsKeyword("DROP"):
+		stmt.Kind = StatementDrop
+		i := skipKeywords(tokens, 1, "MATERIALIZED")
+		if i < len(tokens) && (tokens[i].IsKeyword("TABLE") || tokens[i].IsKeyword("VIEW")) {
+			i = skipKeywords(tokens, i+1, "IF", "EXISTS")
+			stmt.Target, _ = readTableName(tokens, i)
+		}
+	case first.IsKeyword("INSERT"):
+		stmt.Kind = StatementInsert
+		stmt.Target = parseInsertTarget(tokens, 0)
+	case first.IsKeyword("MERGE"):
+		stmt.Kind = StatementMerge
+		stmt.Target, _ = readTableName(tokens, skipKeywords(tokens, 1, "INTO"))
+	case first.IsKeyword("DELETE"):
+		stmt.Kind = StatementDelete
+		stmt.Target, _ = readTableName(tokens, skipKeywords(tokens, 1, "FROM"))
+	case first.IsKeyword("UPDATE"):
+		stmt.Kind = StatementUpdate
+		stmt.Target, _ = readTableName(tokens, 1)
+	case first.IsKeyword("ALTER"):
+		stmt.Kind = StatementAlter
+		stmt.Target, _ = readTableName(tokens, skipKeywords(tokens, 1, "TABLE", "VIEW"))
+	case first.IsKeyword("TRUNCATE"):
+		stmt.Kind = StatementTruncate
+		stmt.Target, _ = readTableName(tokens, skipKeywords(tokens, 1, "TABLE"))
+	case first.IsKeyword("SELECT"), first.IsKeyword("WITH"), first.IsKeyword("FROM"), first.Value == "(":
+		// WITH ... INSERT and FROM ... INSERT are inserts as well
+		stmt.Kind = StatementSelect
+		if i := indexTopLevelKeyword(tokens, 0, "INSERT"); i >= 0 {
+			stmt.Kind = StatementInsert
+			stmt.Target = parseInsertTarget(tokens, i)
+		}
+	}
+	return stmt
+}
+
+// parseCreate classifies CREATE statements
+func parseCreate(tokens []Token) (StatementKind, string) {
+	i := skipKeywords(tokens, 1, "OR", "REPLACE", "TEMPORARY", "EXTERNAL", "TRANSACTIONAL", "DELTA")
+	if i >= len(tokens) {
+		return StatementUnknown, ""
+	}
+	switch {
+	case tokens[i].IsKeyword("TABLE"):
+		i = skipKeywords(tokens, i+1, "IF", "NOT", "EXISTS")
+		target, next := readTableName(tokens, i)
+		if indexTopLevelKeyword(tokens, next, "AS") >= 0 {
+			return StatementCreateTableAs, target
+		}
+		return StatementCreateTable, target
+	case tokens[i].IsKeyword("VIEW"), tokens[i].IsKeyword("MATERIALIZED"):
+		i = skipKeywords(tokens, i, "MATERIALIZED", "VIEW", "IF", "NOT", "EXISTS")
+		target, _ := readTableName(tokens, i)
+		return StatementCreateView, target
+	}
+	return StatementUnknown, ""
+}
+
+// parseInsertTarget returns the table name of INSERT statement starting at index i
+func parseInsertTarget(tokens []Token, i int) string {
+	i = skipKeywords(tokens, i+1, "INTO", "OVERWRITE", "TABLE")
+	target, _ := readTableName(tokens, i)
+	return target
+}
+
+// significantTokens returns tokens without whitespaces and comments
+func significantTokens(tokens []Token) []Token {
+	result := make([]Token, 0, len(tokens))
+	for _, t := range tokens {
+		if t.IsTrivia() {
+			continue
+		}
+		result = append(result, t)
+	}
+	return result
+}
+
+// skipKeywords returns the index of the first token from i
+// which is not one of the given keywords
+func skipKeywords(tokens []Token, i int, keywords ...string) int {
+	for ; i < len(tokens); i++ {
+		matched := false
+		for _, keyword := range keywords {
+			if tokens[i].IsKeyword(keyword) {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			return i
+		}
+	}
+	return i
+}
+
+// readTableName reads dotted table name starting at index i,
+// it returns the name and the index right after the name
+func readTableName(tokens []Token, i int) (string, int) {
+	builder := strings.Builder{}
+	for i < len(tokens) {
+		if tokens[i].Type != TokenWord && tokens[i].Type != TokenQuotedIdentifier {
+			break
+		}
+		builder.WriteString(tokens[i].Value)
+		i++
+		if i < len(tokens) && tokens[i].Value == "." {
+			builder.WriteString(".")
+			i++
+			continue
+		}
+		break
+	}
+	return builder.String(), i
+}
+
+// indexTopLevelKeyword returns the index of the first keyword from index i
+// which is not enclosed in parentheses, or -1 if there's none
+func indexTopLevelKeyword(tokens []Token, i int, keyword string) int {
+	depth := 0
+	for ; i < len(tokens); i++ {
+		switch {
+		case tokens[i].Value == "(":
+			depth++
+		case tokens[i].Value == ")":
+			depth--
+		case depth == 0 && tokens[i].IsKeyword(keyword):
+			return i
+		}
+	}
+	return -1
+}
@@ -0,0 +1,76 @@
+package query_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/goto/transformers/mc2mc/pkg/query"
+)
+
+func TestParseStatements(t *testing.T) {
+	t.Run("classifies statements with their targets", func(t *testing.T) {
+		q1 := `set odps.sql.allow.fullscan=true;
+/* only comment */;
+@src := SELECT 1 id;
+function my_add(@a BIGINT) as @a + 1;
+CREATE TABLE IF NOT EXISTS project.playground.table_a (id bigint);
+CREATE TABLE project.playground.table_b LIFECYCLE 1 AS SELECT * FROM @src;
+CREATE OR REPLACE VIEW IF NOT EXISTS project.playground.view_c AS SELECT 1;
+DROP TABLE IF EXISTS project.playground.table_d;
+INSERT OVERWRITE TABLE project.playground.table_e PARTITION (dt) SELECT * FROM @src;
+MERGE INTO ` + "`project`.playground.`table_f`" + ` t USING (SELECT * FROM @src) s ON t.id = s.id WHEN MATCHED THEN DELETE;
+DELETE FROM project.playground.table_g WHERE id = 1;
+UPDATE project.playground.table_h SET id = 2;
+WITH cte AS (SELECT 1 id) SELECT * FROM cte;
+WITH cte AS (SELECT 1 id) INSERT INTO project.playground.table_i SELECT * FROM cte;
+ALTER TABLE project.playground.table_j ADD COLUMNS (name string);
+TRUNCATE TABLE project.playground.table_k;
+SHOW TABLES;`
+		stmts := query.ParseStatements(q1)
+		expected := []struct {
+			kind   query.StatementKind
+			target string
+		}{
+			{query.StatementSet, ""},
+			{query.StatementEmpty, ""},
+			{query.StatementVariable, "@src"},
+			{query.StatementFunction, "my_add"},
+			{query.StatementCreateTable, "project.playground.table_a"},
+			{query.StatementCreateTableAs, "project.playground.table_b"},
+			{query.StatementCreateView, "project.playground.view_c"},
+			{query.StatementDrop, "project.playground.table_d"},
+			{query.StatementInsert, "project.playground.table_e"},
+			{query.StatementMerge, "`project`.playground.`table_f`"},
+			{query.StatementDelete, "project.playground.table_g"},
+			{query.StatementUpdate, "project.playground.table_h"},
+			{query.StatementSelect, ""},
+			{query.StatementInsert, "project.playground.table_i"},
+			{query.StatementAlter, "project.playground.table_j"},
+			{query.StatementTruncate, "project.playground.table_k"},
+			{query.StatementUnknown, ""},
+		}
+		assert.Len(t, stmts, len(expected))
+		for i, e := range expected {
+			assert.Equal(t, e.kind, stmts[i].Kind, "statement %d: %s", i, stmts[i].Text)
+			assert.Equal(t, e.target, stmts[i].Target, "statement %d: %s", i, stmts[i].Text)
+		}
+	})
+	t.Run("classifies statements with leading comments", func(t *testing.T) {
+		stmts := query.ParseStatements("/* setting */ SET odps.sql.allow.fullscan=true;\n-- drop;\nDROP TABLE t")
+		assert.Len(t, stmts, 2)
+		assert.Equal(t, query.StatementSet, stmts[0].Kind)
+		assert.Equal(t, query.StatementDrop, stmts[1].Kind)
+		assert.Equal(t, "-- drop;\nDROP TABLE t", stmts[1].Text)
+	})
+	t.Run("does not classify create table as select as ddl", func(t *testing.T) {
+		stmts := query.ParseStatements("CREATE TABLE t (id bigint); CREATE TABLE t2 AS SELECT 1 id")
+		assert.True(t, stmts[0].IsDDL())
+		assert.False(t, stmts[1].IsDDL())
+	})
+}
+
+func TestStatementKind_String(t *testing.T) {
+	assert.Equal(t, "CREATE_TABLE_AS", query.StatementCreateTableAs.String())
+	assert.Equal(t, "UNKNOWN", query.StatementKind(255).String())
+}
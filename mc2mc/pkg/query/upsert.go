@@ -0,0 +1,83 @@
+package query
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// constructUpsertQuery constructs MERGE INTO query which updates the destination rows
+// matching the merge keys and inserts the rest, the destination must be a transactional table
+func (b *Builder) constructUpsertQuery(query string) (string, error) {
+	if len(b.mergeKeys) == 0 {
+		return "", errors.New("merge keys are required for upsert method")
+	}
+
+	if err := b.fetchOrderedColumns(); err != nil {
+		return "", errors.WithStack(err)
+	}
+	partitionNames, err := b.client.GetPartitionNames(context.Background(), b.destinationTableID)
+	if err != nil {
+		return "", errors.WithStack(err)
+	}
+
+	// partition columns are inserted after the regular columns but can't be updated
+	columns := append([]string{}, b.orderedColumns...)
+	for _, partitionName := range partitionNames {
+		if _, ok := findColumn(columns, partitionName); !ok {
+			columns = append(columns, partitionName)
+		}
+	}
+
+	keys := make([]string, len(b.mergeKeys))
+	for i, key := range b.mergeKeys {
+		column, ok := findColumn(columns, key)
+		if !ok {
+			return "", errors.Errorf("merge key %s is not found in destination table %s", key, b.destinationTableID)
+		}
+		keys[i] = column
+	}
+
+	projections := make([]string, len(columns))
+	conditions := make([]string, len(keys))
+	updates := []string{}
+	values := make([]string, len(columns))
+	for i, column := range columns {
+		projections[i] = column
+		if val, ok := b.overridedValues[column]; ok {
+			projections[i] = fmt.Sprintf("%s as %s", val, column)
+		}
+		values[i] = fmt.Sprintf("s.%s", column)
+		_, isKey := findColumn(keys, column)
+		_, isPartition := findColumn(partitionNames, column)
+		if !isKey && !isPartition {
+			updates = append(updates, fmt.Sprintf("t.%s = s.%s", column, column))
+		}
+	}
+	for i, key := range keys {
+		conditions[i] = fmt.Sprintf("t.%s = s.%s", key, key)
+	}
+
+	builder := strings.Builder{}
+	builder.WriteString(fmt.Sprintf("MERGE INTO %s AS t\nUSING (\nSELECT %s FROM (\n%s\n)\n) AS s\n", b.destinationTableID, strings.Join(projections, ", "), query))
+	builder.WriteString(fmt.Sprintf("ON %s\n", strings.Join(conditions, " AND ")))
+	if len(updates) > 0 {
+		builder.WriteString(fmt.Sprintf("WHEN MATCHED THEN UPDATE SET %s\n", strings.Join(updates, ", ")))
+	}
+	builder.WriteString(fmt.Sprintf("WHEN NOT MATCHED THEN INSERT VALUES (%s)\n;", strings.Join(values, ", ")))
+	return builder.String(), nil
+}
+
+// findColumn returns the column matching the given name case insensitively,
+// backticks around the names are ignored
+func findColumn(columns []string, name string) (string, bool) {
+	name = strings.Trim(name, "`")
+	for _, column := range columns {
+		if strings.EqualFold(strings.Trim(column, "`"), name) {
+			return column, true
+		}
+	}
+	return "", false
+}
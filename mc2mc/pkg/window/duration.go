@@ -0,0 +1,94 @@
+package window
+
+import (
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/pkg/errors"
+)
+
+// Duration is a window duration, months, weeks and days are kept apart from
+// the clock part so they're stepped on the calendar and not affected by daylight saving time
+type Duration struct {
+	Months int
+	Days   int
+	Clock  time.Duration
+}
+
+// durationUnits are the supported units of the duration, they're the units of
+// pytimeparse which is used by bq2bq, mo is added for calendar month
+var durationUnits = map[string]Duration{
+	"w": {Days: 7}, "wk": {Days: 7}, "wks": {Days: 7}, "week": {Days: 7}, "weeks": {Days: 7},
+	"d": {Days: 1}, "dy": {Days: 1}, "dys": {Days: 1}, "day": {Days: 1}, "days": {Days: 1},
+	"h": {Clock: time.Hour}, "hr": {Clock: time.Hour}, "hrs": {Clock: time.Hour}, "hour": {Clock: time.Hour}, "hours": {Clock: time.Hour},
+	"m": {Clock: time.Minute}, "min": {Clock: time.Minute}, "mins": {Clock: time.Minute}, "minute": {Clock: time.Minute}, "minutes": {Clock: time.Minute},
+	"s": {Clock: time.Second}, "sec": {Clock: time.Second}, "secs": {Clock: time.Second}, "second": {Clock: time.Second}, "seconds": {Clock: time.Second},
+	"mo": {Months: 1}, "month": {Months: 1}, "months": {Months: 1},
+}
+
+// ParseDuration parses durations like 1d, -2h, 1w, 1d12h or 30m, empty or 0 is zero duration.
+// The duration is lowercased as bq2bq does, so 1M is one minute, calendar month is mo.
+func ParseDuration(s string) (Duration, error) {
+	raw := strings.ToLower(strings.Join(strings.Fields(s), ""))
+	if raw == "" || raw == "0" {
+		return Duration{}, nil
+	}
+
+	sign := 1
+	switch raw[0] {
+	case '-':
+		sign = -1
+		raw = raw[1:]
+	case '+':
+		raw = raw[1:]
+	}
+	if raw == "" {
+		return Duration{}, errors.Errorf("invalid duration: %s", s)
+	}
+
+	d := Duration{}
+	for raw != "" {
+		i := 0
+		for i < len(raw) && unicode.IsDigit(rune(raw[i])) {
+			i++
+		}
+		if i == 0 || i == len(raw) {
+			return Duration{}, errors.Errorf("invalid duration: %s", s)
+		}
+		value, err := strconv.Atoi(raw[:i])
+		if err != nil {
+			return Duration{}, errors.Errorf("invalid duration: %s", s)
+		}
+		j := i
+		for j < len(raw) && unicode.IsLetter(rune(raw[j])) {
+			j++
+		}
+		unit, ok := durationUnits[raw[i:j]]
+		if !ok {
+			return Duration{}, errors.Errorf("invalid duration unit %q: %s", raw[i:j], s)
+		}
+		value *= sign
+		d.Months += unit.Months * value
+		d.Days += unit.Days * value
+		d.Clock += unit.Clock * time.Duration(value)
+		raw = raw[j:]
+	}
+	return d, nil
+}
+
+// IsNegative returns true if any part of the duration is negative
+func (d Duration) IsNegative() bool {
+	return d.Months < 0 || d.Days < 0 || d.Clock < 0
+}
+
+// AddTo returns the given time shifted forward by the duration
+func (d Duration) AddTo(t time.Time) time.Time {
+	return t.AddDate(0, d.Months, d.Days).Add(d.Clock)
+}
+
+// SubFrom returns the given time shifted backward by the duration
+func (d Duration) SubFrom(t time.Time) time.Time {
+	return t.Add(-d.Clock).AddDate(0, -d.Months, -d.Days)
+}
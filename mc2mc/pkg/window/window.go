@@ -0,0 +1,67 @@
+// Package window computes the execution window of a job from its scheduled time
+// with the same semantics as bq2bq's WINDOW_SIZE, WINDOW_OFFSET and WINDOW_TRUNCATE_UPTO.
+package window
+
+import (
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// Window is the time range [Start, End) a job is executed for
+type Window struct {
+	Start time.Time
+	End   time.Time
+}
+
+// New returns the window of the given scheduled time, the end of the window is
+// the scheduled time truncated upto the given unit and shifted by the offset,
+// the start is the end minus the size. Times are computed in the given location.
+func New(scheduledAt time.Time, size, offset, truncateUpto string, loc *time.Location) (Window, error) {
+	if loc == nil {
+		loc = time.UTC
+	}
+	windowSize, err := ParseDuration(size)
+	if err != nil {
+		return Window{}, errors.WithStack(err)
+	}
+	if windowSize == (Duration{}) || windowSize.IsNegative() {
+		return Window{}, errors.Errorf("invalid window size: %s", size)
+	}
+	windowOffset, err := ParseDuration(offset)
+	if err != nil {
+		return Window{}, errors.WithStack(err)
+	}
+	end, err := Truncate(scheduledAt.In(loc), truncateUpto)
+	if err != nil {
+		return Window{}, errors.WithStack(err)
+	}
+	end = windowOffset.AddTo(end)
+	return Window{
+		Start: windowSize.SubFrom(end),
+		End:   end,
+	}, nil
+}
+
+// Truncate truncates the given time upto the given unit as bq2bq does:
+// h (hour), d (day) or w (end of the week, the next sunday), empty or 0 keeps the time as is.
+// The unit is case-insensitive, mo (month) is supported on top of bq2bq's units.
+func Truncate(t time.Time, upto string) (time.Time, error) {
+	switch strings.ToLower(upto) {
+	case "", "0":
+		return t, nil
+	case "h":
+		return time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), 0, 0, 0, t.Location()), nil
+	case "d":
+		return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location()), nil
+	case "w":
+		// weeks start on monday, so the sunday of the current week is the end
+		daysToSunday := (7 - int(t.Weekday())) % 7
+		return time.Date(t.Year(), t.Month(), t.Day()+daysToSunday, 0, 0, 0, 0, t.Location()), nil
+	case "mo":
+		return time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, t.Location()), nil
+	default:
+		return t, errors.Errorf("unsupported truncate method: %s", upto)
+	}
+}
@@ -0,0 +1,122 @@
+package window_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/goto/transformers/mc2mc/pkg/window"
+)
+
+func TestNew(t *testing.T) {
+	scheduledAt := time.Date(2020, 7, 9, 4, 0, 0, 0, time.UTC)
+
+	t.Run("returns window of bq2bq for the same configuration", func(t *testing.T) {
+		// cases and expectations of bq2bq/tests/test_window.py
+		testCases := []struct {
+			size, offset, truncateUpto string
+			start, end                 time.Time
+		}{
+			{"24h", "", "", time.Date(2020, 7, 8, 4, 0, 0, 0, time.UTC), time.Date(2020, 7, 9, 4, 0, 0, 0, time.UTC)},
+			{"2d", "1d", "", time.Date(2020, 7, 8, 4, 0, 0, 0, time.UTC), time.Date(2020, 7, 10, 4, 0, 0, 0, time.UTC)},
+			{"2d", "-24h", "", time.Date(2020, 7, 6, 4, 0, 0, 0, time.UTC), time.Date(2020, 7, 8, 4, 0, 0, 0, time.UTC)},
+			{"2h", "0", "h", time.Date(2020, 7, 9, 2, 0, 0, 0, time.UTC), time.Date(2020, 7, 9, 4, 0, 0, 0, time.UTC)},
+			{"2d", "1d", "d", time.Date(2020, 7, 8, 0, 0, 0, 0, time.UTC), time.Date(2020, 7, 10, 0, 0, 0, 0, time.UTC)},
+			{"1w", "24h", "d", time.Date(2020, 7, 3, 0, 0, 0, 0, time.UTC), time.Date(2020, 7, 10, 0, 0, 0, 0, time.UTC)},
+			{"1w", "0", "w", time.Date(2020, 7, 5, 0, 0, 0, 0, time.UTC), time.Date(2020, 7, 12, 0, 0, 0, 0, time.UTC)},
+			{"1w", "2d", "w", time.Date(2020, 7, 7, 0, 0, 0, 0, time.UTC), time.Date(2020, 7, 14, 0, 0, 0, 0, time.UTC)},
+			// bq2bq lowercases the configuration, so M is minute
+			{"1W", "24H", "D", time.Date(2020, 7, 3, 0, 0, 0, 0, time.UTC), time.Date(2020, 7, 10, 0, 0, 0, 0, time.UTC)},
+			{"2D", "-24H", "", time.Date(2020, 7, 6, 4, 0, 0, 0, time.UTC), time.Date(2020, 7, 8, 4, 0, 0, 0, time.UTC)},
+			{"90M", "", "H", time.Date(2020, 7, 9, 2, 30, 0, 0, time.UTC), time.Date(2020, 7, 9, 4, 0, 0, 0, time.UTC)},
+		}
+		for _, tc := range testCases {
+			w, err := window.New(scheduledAt, tc.size, tc.offset, tc.truncateUpto, time.UTC)
+			assert.NoError(t, err)
+			assert.Equal(t, tc.start, w.Start, "size: %s, offset: %s, truncate: %s", tc.size, tc.offset, tc.truncateUpto)
+			assert.Equal(t, tc.end, w.End, "size: %s, offset: %s, truncate: %s", tc.size, tc.offset, tc.truncateUpto)
+		}
+	})
+	t.Run("returns window truncated upto hour", func(t *testing.T) {
+		w, err := window.New(scheduledAt.Add(30*time.Minute), "2h", "0", "h", time.UTC)
+		assert.NoError(t, err)
+		assert.Equal(t, time.Date(2020, 7, 9, 2, 0, 0, 0, time.UTC), w.Start)
+		assert.Equal(t, scheduledAt, w.End)
+	})
+	t.Run("returns window truncated upto week ending on the same sunday", func(t *testing.T) {
+		w, err := window.New(time.Date(2020, 7, 12, 4, 0, 0, 0, time.UTC), "1w", "0", "w", time.UTC)
+		assert.NoError(t, err)
+		assert.Equal(t, time.Date(2020, 7, 5, 0, 0, 0, 0, time.UTC), w.Start)
+		assert.Equal(t, time.Date(2020, 7, 12, 0, 0, 0, 0, time.UTC), w.End)
+	})
+	t.Run("returns window of previous month truncated upto month", func(t *testing.T) {
+		w, err := window.New(scheduledAt, "1mo", "", "mo", time.UTC)
+		assert.NoError(t, err)
+		assert.Equal(t, time.Date(2020, 6, 1, 0, 0, 0, 0, time.UTC), w.Start)
+		assert.Equal(t, time.Date(2020, 7, 1, 0, 0, 0, 0, time.UTC), w.End)
+	})
+	t.Run("returns window truncated in the given timezone", func(t *testing.T) {
+		jakarta, err := time.LoadLocation("Asia/Jakarta")
+		assert.NoError(t, err)
+		w, err := window.New(time.Date(2020, 7, 8, 20, 0, 0, 0, time.UTC), "1d", "", "d", jakarta)
+		assert.NoError(t, err)
+		assert.Equal(t, time.Date(2020, 7, 8, 0, 0, 0, 0, jakarta), w.Start)
+		assert.Equal(t, time.Date(2020, 7, 9, 0, 0, 0, 0, jakarta), w.End)
+	})
+	t.Run("steps days on the calendar across daylight saving time", func(t *testing.T) {
+		amsterdam, err := time.LoadLocation("Europe/Amsterdam")
+		assert.NoError(t, err)
+		w, err := window.New(time.Date(2024, 3, 31, 12, 0, 0, 0, amsterdam), "1d", "", "d", amsterdam)
+		assert.NoError(t, err)
+		assert.Equal(t, time.Date(2024, 3, 30, 0, 0, 0, 0, amsterdam), w.Start)
+		assert.Equal(t, time.Date(2024, 3, 31, 0, 0, 0, 0, amsterdam), w.End)
+	})
+	t.Run("returns error for zero window size", func(t *testing.T) {
+		_, err := window.New(scheduledAt, "0", "", "", time.UTC)
+		assert.ErrorContains(t, err, "invalid window size: 0")
+	})
+	t.Run("returns error for unsupported truncate method", func(t *testing.T) {
+		_, err := window.New(scheduledAt, "1d", "", "y", time.UTC)
+		assert.ErrorContains(t, err, "unsupported truncate method: y")
+		// truncate is lowercased as bq2bq does, which doesn't support minute
+		_, err = window.New(scheduledAt, "1d", "", "M", time.UTC)
+		assert.ErrorContains(t, err, "unsupported truncate method: M")
+	})
+}
+
+func TestParseDuration(t *testing.T) {
+	t.Run("parses compound duration", func(t *testing.T) {
+		d, err := window.ParseDuration("1d12h30m")
+		assert.NoError(t, err)
+		assert.Equal(t, window.Duration{Days: 1, Clock: 12*time.Hour + 30*time.Minute}, d)
+	})
+	t.Run("parses negative duration", func(t *testing.T) {
+		d, err := window.ParseDuration("-1w")
+		assert.NoError(t, err)
+		assert.Equal(t, window.Duration{Days: -7}, d)
+	})
+	t.Run("parses case-insensitive duration with minute as M", func(t *testing.T) {
+		d, err := window.ParseDuration("1D2H30M")
+		assert.NoError(t, err)
+		assert.Equal(t, window.Duration{Days: 1, Clock: 2*time.Hour + 30*time.Minute}, d)
+	})
+	t.Run("parses long unit names of pytimeparse", func(t *testing.T) {
+		d, err := window.ParseDuration("1 week 2 days 3 hrs 4 mins 5 secs")
+		assert.NoError(t, err)
+		assert.Equal(t, window.Duration{Days: 9, Clock: 3*time.Hour + 4*time.Minute + 5*time.Second}, d)
+	})
+	t.Run("parses calendar month", func(t *testing.T) {
+		d, err := window.ParseDuration("-1mo")
+		assert.NoError(t, err)
+		assert.Equal(t, window.Duration{Months: -1}, d)
+	})
+	t.Run("returns error for invalid duration", func(t *testing.T) {
+		_, err := window.ParseDuration("1x")
+		assert.ErrorContains(t, err, "invalid duration unit")
+		_, err = window.ParseDuration("1y")
+		assert.ErrorContains(t, err, "invalid duration unit")
+		_, err = window.ParseDuration("d")
+		assert.ErrorContains(t, err, "invalid duration: d")
+	})
+}
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/pkg/errors"
+
+	"github.com/goto/transformers/mc2mc/internal/client"
+	"github.com/goto/transformers/mc2mc/internal/config"
+	"github.com/goto/transformers/mc2mc/internal/logger"
+	"github.com/goto/transformers/mc2mc/pkg/query"
+)
+
+// render prints the final queries of the job to the writer without executing them.
+// Table schemas are read from SCHEMA_FILE_PATH (file or directory) when it's set, otherwise from ODPS.
+func render(envs []string, w io.Writer) error {
+	// load config
+	cfg, err := config.NewConfig(envs...)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+
+	// set up logger
+	l, err := logger.NewLogger(cfg.LogLevel)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+
+	var odpsClient query.OdpsClient
+	switch {
+	case cfg.SchemaFilePath != "":
+		odpsClient, err = client.NewFileSchemaClient(cfg.SchemaFilePath)
+		if err != nil {
+			return errors.WithStack(err)
+		}
+	case cfg.MCServiceAccount != "":
+		odpsClient = client.NewODPSClient(l, cfg.GenOdps())
+	default:
+		return errors.New("either SCHEMA_FILE_PATH or MC_SERVICE_ACCOUNT is required to render queries")
+	}
+
+	raw, err := os.ReadFile(cfg.QueryFilePath)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+
+	generatedQueries, err := generateJobQueries(l, cfg, query.NewCachedClient(odpsClient), string(raw), nil)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+
+	for i, generatedQuery := range generatedQueries {
+		header := fmt.Sprintf("-- [sequence: %d] load method: %s, dstart: %s, dend: %s, date: %s",
+			i+1, cfg.Method(), cfg.DStart, cfg.DEnd, generatedQuery.date)
+		if _, err := fmt.Fprintf(w, "%s\n%s\n\n", header, strings.TrimSpace(generatedQuery.query)); err != nil {
+			return errors.WithStack(err)
+		}
+	}
+	return nil
+}
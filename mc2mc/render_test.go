@@ -0,0 +1,56 @@
+package main
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRender(t *testing.T) {
+	envs := []string{
+		"LOAD_METHOD=REPLACE",
+		"QUERY_FILE_PATH=testdata/query.sql",
+		"DESTINATION_TABLE_ID=project.playground.table_destination",
+		"DSTART=2024-01-01T00:00:00Z",
+		"DEND=2024-01-03T00:00:00Z",
+	}
+
+	t.Run("renders queries offline from schema file without credential", func(t *testing.T) {
+		expected := `-- [sequence: 1] load method: REPLACE, dstart: 2024-01-01T00:00:00Z, dend: 2024-01-03T00:00:00Z, date: 2024-01-01 00:00:00
+INSERT OVERWRITE TABLE project.playground.table_destination PARTITION (dt) 
+SELECT id, name FROM (
+SELECT id, name FROM (
+select id, name from project.playground.table_source where dt = '2024-01-01'
+)
+)
+;
+
+-- [sequence: 2] load method: REPLACE, dstart: 2024-01-01T00:00:00Z, dend: 2024-01-03T00:00:00Z, date: 2024-01-02 00:00:00
+INSERT OVERWRITE TABLE project.playground.table_destination PARTITION (dt) 
+SELECT id, name FROM (
+SELECT id, name FROM (
+select id, name from project.playground.table_source where dt = '2024-01-02'
+)
+)
+;
+
+`
+		var out bytes.Buffer
+		err := render(append(envs, "SCHEMA_FILE_PATH=testdata/schema.json"), &out)
+		assert.NoError(t, err)
+		assert.Equal(t, expected, out.String())
+	})
+	t.Run("returns error when table schema is not in schema file", func(t *testing.T) {
+		var out bytes.Buffer
+		err := render(append(envs, "SCHEMA_FILE_PATH=testdata/schema.json", "DESTINATION_TABLE_ID=project.playground.table_unknown"), &out)
+		assert.ErrorContains(t, err, "schema of table project.playground.table_unknown is not found")
+		assert.Empty(t, out.String())
+	})
+	t.Run("returns error when neither schema file nor credential is set", func(t *testing.T) {
+		var out bytes.Buffer
+		err := render(envs, &out)
+		assert.ErrorContains(t, err, "either SCHEMA_FILE_PATH or MC_SERVICE_ACCOUNT is required to render queries")
+		assert.Empty(t, out.String())
+	})
+}
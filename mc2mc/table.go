@@ -0,0 +1,72 @@
+package main
+
+import (
+	"context"
+	e "errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/goto/transformers/mc2mc/internal/client"
+	"github.com/goto/transformers/mc2mc/internal/config"
+	"github.com/goto/transformers/mc2mc/pkg/query"
+)
+
+// tableClient provides table metadata to create or alter the destination table
+type tableClient interface {
+	TableExists(tableID string) (bool, error)
+	GetColumns(tableID string) ([]query.Column, error)
+}
+
+// createDestinationTable creates the destination table if it doesn't exist yet
+// with the columns inferred from the query result and configured partition columns.
+// It returns false on dry run when the destination doesn't exist since nothing is created.
+func createDestinationTable(ctx context.Context, l *slog.Logger, cfg *config.Config, c *client.Client, tc tableClient, raw string) (bool, error) {
+	exists, err := tc.TableExists(cfg.DestinationTableID)
+	if err != nil {
+		return false, errors.WithStack(err)
+	}
+	if exists {
+		return true, nil
+	}
+	if cfg.DryRun {
+		l.Info(fmt.Sprintf("[DRY-RUN] destination table %s doesn't exist and would be created", cfg.DestinationTableID))
+		return false, nil
+	}
+
+	l.Info(fmt.Sprintf("destination table %s doesn't exist, inferring its schema from the query", cfg.DestinationTableID))
+	columns, err := inferQueryColumns(ctx, cfg, c, tc, raw)
+	if err != nil {
+		return false, errors.WithStack(err)
+	}
+	createQuery := query.ConstructCreateTableQuery(cfg.DestinationTableID, columns, query.TableSpec{
+		PartitionColumns: query.ParseColumns(cfg.AutoCreateTablePartitionColumns),
+		Lifecycle:        cfg.AutoCreateTableLifecycle,
+		Comment:          cfg.AutoCreateTableComment,
+	})
+	if err := c.ExecuteFn(0)(ctx, createQuery, cfg.AdditionalHints); err != nil {
+		return false, errors.WithStack(err)
+	}
+	l.Info(fmt.Sprintf("destination table %s is created", cfg.DestinationTableID))
+	return true, nil
+}
+
+// inferQueryColumns returns the result columns of the query along with their types
+// by creating an empty temporary table from it, the temporary table is dropped afterwards.
+func inferQueryColumns(ctx context.Context, cfg *config.Config, c *client.Client, tc tableClient, raw string) (columns []query.Column, err error) {
+	executeFn := c.ExecuteFn(0)
+	tmpTableID := fmt.Sprintf("%s_mc2mc_schema_%d", cfg.DestinationTableID, time.Now().Unix())
+	if err := executeFn(ctx, query.ConstructSchemaInferenceQuery(tmpTableID, raw), cfg.AdditionalHints); err != nil {
+		return nil, errors.WithStack(err)
+	}
+	defer func() {
+		if dropErr := executeFn(context.WithoutCancel(ctx), query.ConstructDropTableQuery(tmpTableID), cfg.AdditionalHints); dropErr != nil {
+			err = e.Join(err, errors.WithStack(dropErr))
+		}
+	}()
+
+	columns, err = tc.GetColumns(tmpTableID)
+	return columns, errors.WithStack(err)
+}
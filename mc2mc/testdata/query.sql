@@ -0,0 +1,3 @@
+select id, name from project.playground.table_source where dt = '2024-01-01';
+--*--optimus-break-marker--*--
+select id, name from project.playground.table_source where dt = '2024-01-02';